@@ -50,4 +50,13 @@ var (
 
 	// RedisKeyPrefixSignature Cache Key 前缀 - 签名验证信息
 	RedisKeyPrefixSignature = Settings.Base.Name + ":signature:"
+
+	// RedisKeyPrefixRateLimit Cache Key 前缀 - 限流计数
+	RedisKeyPrefixRateLimit = Settings.Base.Name + ":rate-limit:"
+
+	// RedisKeyPrefixIdempotency Cache Key 前缀 - 幂等请求的结果
+	RedisKeyPrefixIdempotency = Settings.Base.Name + ":idempotency:"
+
+	// RedisKeyPrefixAgent Cache Key 前缀 - 备份代理的注册码、证书签发记录及吊销列表
+	RedisKeyPrefixAgent = Settings.Base.Name + ":agent:"
 )
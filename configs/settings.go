@@ -2,24 +2,32 @@ package configs
 
 import (
 	"embed"
+	"fmt"
 	"gopkg.in/ini.v1"
 	"log"
+	"os"
+	"path/filepath"
 	"reflect"
 )
 
 // basicSettings 服务的基本配置
 type basicSettings struct {
-	Name           string `json:"name"`
-	Version        string `json:"version"`
-	StartupMode    string `json:"startup_mode"`
-	DisplayName    string `json:"display_name"`
-	Description    string `json:"description"`
-	SrvDepends     string `json:"srv_depends"`
-	SrvProtocol    string `json:"srv_protocol"`
-	SrvIP          string `json:"srv_http_ip"`
-	SrvPort        string `json:"srv_http_port"`
-	GlobalLogPath  string `json:"global_log_path"`
-	CronLoggerPath string `json:"cron_logger_path"`
+	Name            string `json:"name"`
+	Version         string `json:"version"`
+	StartupMode     string `json:"startup_mode"`
+	DisplayName     string `json:"display_name"`
+	Description     string `json:"description"`
+	SrvDepends      string `json:"srv_depends"`
+	SrvProtocol     string `json:"srv_protocol"`
+	SrvIP           string `json:"srv_http_ip"`
+	SrvPort         string `json:"srv_http_port"`
+	GlobalLogPath   string `json:"global_log_path"`
+	CronLoggerPath  string `json:"cron_logger_path"`
+	SwaggerPath     string `json:"swagger_path"`
+	MaxBodySize     int    `json:"max_body_size"`
+	CompressSize    int    `json:"compress_min_size"`
+	IdempotencyTTL  int    `json:"idempotency_ttl"`
+	ShutdownTimeout int    `json:"shutdown_timeout"`
 }
 
 // postgresqlSettings 服务所依赖的postgresql连接配置
@@ -47,57 +55,217 @@ type redisSettings struct {
 	PoolSize    int `json:"pool_size"`
 }
 
+// alertSettings 告警通知配置，未配置的渠道不启用
+type alertSettings struct {
+	SMTPHost     string `json:"smtp_host"`
+	SMTPPort     int    `json:"smtp_port"`
+	SMTPUser     string `json:"smtp_user"`
+	SMTPPassword string `json:"smtp_password"`
+	MailFrom     string `json:"mail_from"`
+	MailTo       string `json:"mail_to"`
+	WebhookURL   string `json:"webhook_url"`
+	FilePath     string `json:"file_path"`
+
+	DedupeWindow int `json:"dedupe_window"`
+	MaxPerMinute int `json:"max_per_minute"`
+}
+
+// traceSettings Trace 脱敏配置，多个值以逗号分隔
+type traceSettings struct {
+	RedactHeaders       string `json:"redact_headers"`
+	RedactJSONFields    string `json:"redact_json_fields"`
+	RedactPatterns      string `json:"redact_patterns"`
+	RedactRedisPrefixes string `json:"redact_redis_prefixes"`
+}
+
+// securitySettings 跨域及安全 Header 配置，为空的字段使用运行环境的默认值
+type securitySettings struct {
+	CORSAllowOrigins     string `json:"cors_allow_origins"`
+	CORSAllowMethods     string `json:"cors_allow_methods"`
+	CORSAllowHeaders     string `json:"cors_allow_headers"`
+	CORSExposeHeaders    string `json:"cors_expose_headers"`
+	CORSAllowCredentials bool   `json:"cors_allow_credentials"`
+	CORSMaxAge           int    `json:"cors_max_age"`
+
+	ContentSecurityPolicy string `json:"content_security_policy"`
+	HSTSMaxAge            int    `json:"hsts_max_age"`
+	FrameOptions          string `json:"frame_options"`
+	ReferrerPolicy        string `json:"referrer_policy"`
+	TrustedProxies        string `json:"trusted_proxies"`
+}
+
+// rateLimitSettings 限流配置，每秒请求数上限，0 表示不限制；全局上限为 MaxRequestsPerSecond
+type rateLimitSettings struct {
+	Backend  string `json:"backend"`
+	PerIP    int    `json:"per_ip"`
+	PerUser  int    `json:"per_user"`
+	PerAlias int    `json:"per_alias"`
+}
+
+// tlsSettings srv_protocol = https 时的证书及 TLS 配置
+type tlsSettings struct {
+	CertFile         string `json:"cert_file"`
+	KeyFile          string `json:"key_file"`
+	MinVersion       string `json:"min_version"`
+	CipherSuites     string `json:"cipher_suites"`
+	RedirectHTTPPort string `json:"redirect_http_port"`
+}
+
+// agentSettings 备份代理的 mTLS 端口及内置 CA 配置
+type agentSettings struct {
+	MTLSPort          string `json:"mtls_port"`
+	MTLSHosts         string `json:"mtls_hosts"`
+	CACertFile        string `json:"ca_cert_file"`
+	CAKeyFile         string `json:"ca_key_file"`
+	CertValidity      int    `json:"cert_validity"`
+	EnrollmentCodeTTL int    `json:"enrollment_code_ttl"`
+}
+
+// metricsSettings Prometheus 指标接口，仅在独立的管理端口提供
+type metricsSettings struct {
+	Listen string `json:"listen"`
+	Token  string `json:"token"`
+}
+
 type Ss struct {
-	Base  basicSettings
-	DB    postgresqlSettings
-	Cache redisSettings
+	Base      basicSettings
+	DB        postgresqlSettings
+	Cache     redisSettings
+	Alert     alertSettings
+	Trace     traceSettings
+	Security  securitySettings
+	RateLimit rateLimitSettings
+	TLS       tlsSettings
+	Agent     agentSettings
+	Metrics   metricsSettings
 }
 
 var Settings = Load()
 
-// go:embed configs
+// ConfigFileName 配置文件名，部署时与可执行文件放置于同一目录
+const ConfigFileName = "dashboard.ini"
+
+//go:embed dashboard.ini
 var configFiles embed.FS
 
+// readConfig 读取编译时嵌入的默认配置，及可执行文件同目录下部署的配置文件(不存在时为 nil)
+func readConfig() (defaults, deployed []byte, err error) {
+	if defaults, err = configFiles.ReadFile(ConfigFileName); err != nil {
+		return nil, nil, err
+	}
+
+	if exe, exeErr := os.Executable(); exeErr == nil {
+		deployed, err = os.ReadFile(filepath.Join(filepath.Dir(exe), ConfigFileName))
+		if os.IsNotExist(err) {
+			return defaults, nil, nil
+		}
+	}
+	return defaults, deployed, err
+}
+
 func Load() Ss {
+	defaults, deployed, err := readConfig()
+	if err != nil {
+		log.Fatal("load basic config: ", err)
+	}
+
+	s, err := parseConfig(defaults, deployed)
+	if err != nil {
+		log.Fatal("parse basic config: ", err)
+	}
+	return s
+}
+
+// parseConfig 以部署的配置覆盖嵌入的默认配置：升级后部署的配置文件中缺少的新 Key 使用默认值，
+// 默认配置中不存在的 Key(如已更名的旧 Key)被忽略并输出警告
+func parseConfig(defaults, deployed []byte) (Ss, error) {
 	s := new(Ss)
 
-	data, er := configFiles.ReadFile("configs/dashboard.ini")
-	if er != nil {
-		log.Fatal("load basic config: ", er)
+	sources := []interface{}{defaults}
+	if deployed != nil {
+		sources = append(sources, deployed)
+	}
+	cfg, err := ini.Load(sources[0], sources[1:]...)
+	if err != nil {
+		return *s, err
 	}
-	cfg, el := ini.Load(data)
-	if el != nil {
-		log.Fatal("load basic config stream: ", el)
+	if deployed != nil {
+		if err = warnUnknownKeys(defaults, deployed); err != nil {
+			return *s, err
+		}
 	}
 
-	parse := func(_section *ini.Section, _setting any) {
-		bt := reflect.TypeOf(_setting)
-		bv := reflect.ValueOf(_setting)
+	parse := func(_section *ini.Section, _setting any) error {
+		bt := reflect.TypeOf(_setting).Elem()
+		bv := reflect.ValueOf(_setting).Elem()
 
 		for i := 0; i < bt.NumField(); i++ {
-			field := bt.Field(i).Tag.Get("json")
-			key, err := _section.GetKey(field)
+			key, err := _section.GetKey(bt.Field(i).Tag.Get("json"))
 			if err != nil {
-				log.Fatal("parse basic config stream: ", err)
+				return err
 			}
 			switch bt.Field(i).Type.Kind() {
 			case reflect.Int:
 				v, ev := key.Int64()
 				if ev != nil {
-					log.Fatal("convert config to int: ", ev)
+					return fmt.Errorf("convert config [%s] %s to int: %w", _section.Name(), key.Name(), ev)
 				}
 				bv.Field(i).SetInt(v)
 			case reflect.String:
 				bv.Field(i).SetString(key.String())
+			case reflect.Bool:
+				v, ev := key.Bool()
+				if ev != nil {
+					return fmt.Errorf("convert config [%s] %s to bool: %w", _section.Name(), key.Name(), ev)
+				}
+				bv.Field(i).SetBool(v)
 			default:
-				log.Fatal("invalid config type")
+				return fmt.Errorf("invalid config type of [%s] %s", _section.Name(), key.Name())
 			}
 		}
+		return nil
 	}
 
-	parse(cfg.Section(""), &s.Base)
-	parse(cfg.Section("db"), &s.DB)
-	parse(cfg.Section("cache"), &s.Cache)
+	for _, section := range []struct {
+		name    string
+		setting any
+	}{
+		{"", &s.Base},
+		{"db", &s.DB},
+		{"cache", &s.Cache},
+		{"alert", &s.Alert},
+		{"trace", &s.Trace},
+		{"security", &s.Security},
+		{"rate_limit", &s.RateLimit},
+		{"tls", &s.TLS},
+		{"agent", &s.Agent},
+		{"metrics", &s.Metrics},
+	} {
+		if err = parse(cfg.Section(section.name), section.setting); err != nil {
+			return *s, err
+		}
+	}
+
+	return *s, nil
+}
+
+// warnUnknownKeys 部署的配置中存在默认配置没有的 Key 时输出警告，通常为已更名或已废弃的配置
+func warnUnknownKeys(defaults, deployed []byte) error {
+	def, err := ini.Load(defaults)
+	if err != nil {
+		return err
+	}
+	dep, err := ini.Load(deployed)
+	if err != nil {
+		return err
+	}
 
-	return *s
+	for _, section := range dep.Sections() {
+		for _, key := range section.Keys() {
+			if !def.Section(section.Name()).HasKey(key.Name()) {
+				log.Printf("ignore unknown config key [%s] %s, see %s for the current keys", section.Name(), key.Name(), ConfigFileName)
+			}
+		}
+	}
+	return nil
 }
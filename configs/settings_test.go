@@ -0,0 +1,83 @@
+package configs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	defaults, err := configFiles.ReadFile(ConfigFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		deployed string
+		check    func(s Ss) bool
+		wantErr  string
+	}{
+		{
+			name:  "embedded only",
+			check: func(s Ss) bool { return s.Base.Name == "dashboard" && s.Metrics.Listen == "127.0.0.1:9100" },
+		},
+		{
+			name:     "deployed overrides",
+			deployed: "name = aio\nsrv_http_port = 9000\n[db]\nhost = 10.0.0.1\n",
+			check: func(s Ss) bool {
+				return s.Base.Name == "aio" && s.Base.SrvPort == "9000" && s.DB.Host == "10.0.0.1"
+			},
+		},
+		{
+			name:     "keys missing after upgrade use defaults",
+			deployed: "name = aio\n[db]\nhost = 10.0.0.1\n",
+			check: func(s Ss) bool {
+				return s.TLS.MinVersion == "1.2" && s.RateLimit.Backend == "local" && s.Agent.CertValidity == 90 && s.Base.ShutdownTimeout > 0
+			},
+		},
+		{
+			name:     "unknown keys ignored",
+			deployed: "cron_log_path = /tmp/cron.log\n[removed]\nkey = 1\n",
+			check:    func(s Ss) bool { return s.Base.CronLoggerPath == "/var/log/aio/dashboard/dashboard_cron.log" },
+		},
+		{
+			name:     "invalid int",
+			deployed: "[agent]\ncert_validity = ninety\n",
+			wantErr:  "cert_validity",
+		},
+		{
+			name:     "invalid bool",
+			deployed: "[security]\ncors_allow_credentials = maybe\n",
+			wantErr:  "cors_allow_credentials",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deployed []byte
+			if tt.deployed != "" {
+				deployed = []byte(tt.deployed)
+			}
+
+			s, err := parseConfig(defaults, deployed)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.check(s) {
+				t.Errorf("unexpected settings: %+v", s)
+			}
+		})
+	}
+}
+
+func TestParseConfigMissingDefaultKey(t *testing.T) {
+	if _, err := parseConfig([]byte("name = a\n"), nil); err == nil {
+		t.Fatal("want error when the embedded default lacks a key")
+	}
+}
@@ -0,0 +1,410 @@
+package agent
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base32"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kisun-bit/aio_dashboard/configs"
+	"github.com/kisun-bit/aio_dashboard/internal/depends/redis"
+	"github.com/kisun-bit/aio_dashboard/internal/proposal"
+	"github.com/kisun-bit/aio_dashboard/pkg/tlsutil"
+)
+
+const (
+	// crlValidity CRL 的有效期，代理须在到期前重新获取
+	crlValidity = 24 * time.Hour
+	// revokedRefresh 吊销列表的本地缓存时长，其他实例的吊销最迟在此时长后生效
+	revokedRefresh = 30 * time.Second
+	// serverCertValidity mTLS 端口服务端证书的有效期，每次启动重新签发
+	serverCertValidity = 90 * 24 * time.Hour
+)
+
+var (
+	// ErrEnrollmentCode 注册码无效、已过期或已被使用
+	ErrEnrollmentCode = errors.New("enrollment code is invalid or already used")
+	// ErrInvalidCSR 证书签名请求无法解析、签名无效或公钥类型不受支持
+	ErrInvalidCSR = errors.New("invalid certificate request")
+	// ErrRevoked 证书已被吊销
+	ErrRevoked = errors.New("certificate has been revoked")
+	// ErrInvalidSerial 证书序列号不是十六进制数
+	ErrInvalidSerial = errors.New("invalid certificate serial")
+	// ErrUnknownCertificate 证书不是由内置 CA 签发给代理的，或已过期
+	ErrUnknownCertificate = errors.New("certificate was not issued by this ca or has expired")
+)
+
+// Option 自定义 Authority 配置
+type Option func(*option)
+
+type option struct {
+	certValidity time.Duration
+	codeTTL      time.Duration
+}
+
+// WithCertValidity 设置签发的客户端证书有效期，默认 90 天
+func WithCertValidity(validity time.Duration) Option {
+	return func(opt *option) {
+		if validity > 0 {
+			opt.certValidity = validity
+		}
+	}
+}
+
+// WithCodeTTL 设置注册码的有效期，默认 24 小时
+func WithCodeTTL(ttl time.Duration) Option {
+	return func(opt *option) {
+		if ttl > 0 {
+			opt.codeTTL = ttl
+		}
+	}
+}
+
+// Certificate 签发给代理的证书
+type Certificate struct {
+	AgentID     string    `json:"agent_id"`    // 代理ID
+	Serial      string    `json:"serial"`      // 证书序列号(十六进制)
+	NotAfter    time.Time `json:"not_after"`   // 到期时间，到期前通过续期接口更换
+	Certificate string    `json:"certificate"` // PEM 格式的客户端证书
+	CA          string    `json:"ca"`          // PEM 格式的 CA 证书，用于验证 mTLS 端口
+}
+
+// IssuedCertificate 签发记录，保存至证书到期，吊销时据此确认证书及其到期时间
+type IssuedCertificate struct {
+	AgentID  string    `json:"agent_id"`  // 代理ID
+	Serial   string    `json:"serial"`    // 证书序列号(十六进制)
+	NotAfter time.Time `json:"not_after"` // 到期时间，到期后从吊销列表中移除
+}
+
+// EnrollmentCode 一次性注册码
+type EnrollmentCode struct {
+	Code      string    `json:"code"`       // 注册码，仅在创建时返回
+	AgentID   string    `json:"agent_id"`   // 注册后代理证书的ID
+	ExpiresAt time.Time `json:"expires_at"` // 过期时间
+}
+
+type enrollment struct {
+	AgentID   string `json:"agent_id"`
+	CreatedBy string `json:"created_by"`
+}
+
+type revokedEntry struct {
+	RevokedAt time.Time `json:"revoked_at"`
+	NotAfter  time.Time `json:"not_after"`
+}
+
+// revocations 吊销列表，保存于 Redis 的同一个 Key 中，Number 随每次吊销递增
+type revocations struct {
+	Number  int64                    `json:"number"`
+	Entries map[string]*revokedEntry `json:"entries"` // 序列号 -> 吊销信息
+}
+
+// Authority 备份代理的证书管理：通过一次性注册码签发客户端证书、续期、吊销及生成 CRL；
+// 注册码及吊销列表保存于 Redis，多个 dashboard 实例共享
+type Authority struct {
+	ca    *tlsutil.CA
+	cache redis.Operator
+	opt   *option
+
+	mux      sync.RWMutex
+	revoked  *revocations
+	loadedAt time.Time
+}
+
+// New 创建 Authority
+func New(ca *tlsutil.CA, cache redis.Operator, options ...Option) *Authority {
+	opt := &option{certValidity: 90 * 24 * time.Hour, codeTTL: 24 * time.Hour}
+	for _, f := range options {
+		f(opt)
+	}
+
+	return &Authority{ca: ca, cache: cache, opt: opt}
+}
+
+// CreateEnrollmentCode 创建一次性注册码，agentID 为空时随机生成
+func (a *Authority) CreateEnrollmentCode(agentID, createdBy string) (*EnrollmentCode, error) {
+	raw := make([]byte, 20)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	code := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(raw)
+
+	if agentID = strings.TrimSpace(agentID); agentID == "" {
+		agentID = "agent-" + strings.ToLower(code[:12])
+	}
+
+	value, _ := json.Marshal(&enrollment{AgentID: agentID, CreatedBy: createdBy})
+	if err := a.cache.Set(enrollmentKey(code), string(value), a.opt.codeTTL); err != nil {
+		return nil, err
+	}
+
+	return &EnrollmentCode{Code: code, AgentID: agentID, ExpiresAt: time.Now().Add(a.opt.codeTTL)}, nil
+}
+
+// Enroll 使用注册码签发客户端证书，注册码使用后失效；证书签名请求无效或签发失败时注册码仍可使用
+func (a *Authority) Enroll(code string, csrPEM []byte) (*Certificate, error) {
+	csr, err := parseCSR(csrPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	key := enrollmentKey(strings.ToUpper(strings.TrimSpace(code)))
+	if !a.cache.Exists(key) {
+		return nil, ErrEnrollmentCode
+	}
+	value, err := a.cache.Get(key)
+	if err != nil {
+		return nil, ErrEnrollmentCode
+	}
+	info := new(enrollment)
+	if err = json.Unmarshal([]byte(value), info); err != nil {
+		return nil, err
+	}
+	ttl, err := a.cache.TTL(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// 并发使用同一注册码时仅删除成功的请求可继续
+	if !a.cache.Del(key) {
+		return nil, ErrEnrollmentCode
+	}
+
+	cert, err := a.issue(info.AgentID, csr)
+	if err != nil {
+		// 签发失败时恢复注册码，代理可使用同一注册码重试
+		if ttl > 0 {
+			_ = a.cache.Set(key, value, ttl)
+		}
+		return nil, err
+	}
+	return cert, nil
+}
+
+// Renew 为已认证的代理签发新证书并吊销当前证书，每个代理同一时刻仅持有一张有效证书；
+// 当前证书已被吊销(如使用同一证书并发续期)时返回 ErrRevoked，新证书随之作废
+func (a *Authority) Renew(identity proposal.AgentIdentity, csrPEM []byte) (*Certificate, error) {
+	if identity.AgentID == "" || identity.Serial == "" {
+		return nil, errors.New("agent identity required")
+	}
+	csr, err := parseCSR(csrPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := a.issue(identity.AgentID, csr)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = a.revoke(identity.Serial, true); err != nil {
+		// 当前证书未能吊销时作废新证书，代理可继续使用当前证书重试
+		if _, revokeErr := a.revoke(cert.Serial, false); revokeErr != nil {
+			return nil, fmt.Errorf("%w, and the renewed certificate %s is not revoked: %v", err, cert.Serial, revokeErr)
+		}
+		return nil, err
+	}
+	return cert, nil
+}
+
+// parseCSR 校验证书签名请求，错误均包装为 ErrInvalidCSR
+func parseCSR(csrPEM []byte) (*x509.CertificateRequest, error) {
+	csr, err := tlsutil.ParseCSR(csrPEM)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCSR, err)
+	}
+	return csr, nil
+}
+
+// issue 签发客户端证书并保存签发记录，记录保存失败时不返回证书，避免签发无法吊销的证书
+func (a *Authority) issue(agentID string, csr *x509.CertificateRequest) (*Certificate, error) {
+	cert, err := a.ca.IssueClientRequest(csr, agentID, a.opt.certValidity)
+	if err != nil {
+		return nil, err
+	}
+
+	issued := &IssuedCertificate{AgentID: agentID, Serial: cert.SerialNumber.Text(16), NotAfter: cert.NotAfter}
+	value, _ := json.Marshal(issued)
+	if err = a.cache.Set(issuedKey(issued.Serial), string(value), time.Until(cert.NotAfter)); err != nil {
+		return nil, err
+	}
+
+	return &Certificate{
+		AgentID:     agentID,
+		Serial:      issued.Serial,
+		NotAfter:    cert.NotAfter,
+		Certificate: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})),
+		CA:          string(a.ca.PEM()),
+	}, nil
+}
+
+// Identify 将已通过 CA 验证的客户端证书转换为代理身份，证书已吊销时返回 ErrRevoked
+func (a *Authority) Identify(cert *x509.Certificate) (proposal.AgentIdentity, error) {
+	identity := proposal.AgentIdentity{
+		AgentID:  cert.Subject.CommonName,
+		Serial:   cert.SerialNumber.Text(16),
+		NotAfter: cert.NotAfter,
+	}
+
+	revoked, err := a.revokedList(false)
+	if err != nil {
+		return identity, err
+	}
+	if _, ok := revoked.Entries[identity.Serial]; ok {
+		return identity, ErrRevoked
+	}
+	return identity, nil
+}
+
+// Revoke 吊销内置 CA 签发的证书，到期时间取自签发记录，到期后从吊销列表中移除；
+// 未签发或已过期的证书返回 ErrUnknownCertificate
+func (a *Authority) Revoke(serial string) (*IssuedCertificate, error) {
+	n, ok := new(big.Int).SetString(strings.TrimSpace(serial), 16)
+	if !ok {
+		return nil, ErrInvalidSerial
+	}
+	return a.revoke(n.Text(16), false)
+}
+
+// revoke 吊销小写十六进制序列号为 serial 的证书；exclusive 为 true 时证书已被吊销则返回 ErrRevoked
+func (a *Authority) revoke(serial string, exclusive bool) (*IssuedCertificate, error) {
+	key := issuedKey(serial)
+	if !a.cache.Exists(key) {
+		return nil, ErrUnknownCertificate
+	}
+	value, err := a.cache.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	issued := new(IssuedCertificate)
+	if err = json.Unmarshal([]byte(value), issued); err != nil {
+		return nil, err
+	}
+
+	// 吊销操作很少，以带持有者的锁互斥，避免并发吊销时相互覆盖
+	owner := make([]byte, 16)
+	if _, err = rand.Read(owner); err != nil {
+		return nil, err
+	}
+	lockKey := configs.RedisKeyPrefixAgent + "revoked:lock"
+	locked, err := a.cache.SetNX(lockKey, hex.EncodeToString(owner), 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, errors.New("another revocation is in progress, please retry")
+	}
+	defer func() {
+		_, _ = a.cache.Eval(redis.DelIfEqualScript, []string{lockKey}, hex.EncodeToString(owner))
+	}()
+
+	revoked, err := a.revokedList(true)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := revoked.Entries[serial]; ok && exclusive {
+		return nil, ErrRevoked
+	}
+
+	now := time.Now()
+	for key, entry := range revoked.Entries {
+		if now.After(entry.NotAfter) {
+			delete(revoked.Entries, key)
+		}
+	}
+	revoked.Entries[serial] = &revokedEntry{RevokedAt: now, NotAfter: issued.NotAfter}
+	revoked.Number++
+
+	raw, _ := json.Marshal(revoked)
+	if err = a.cache.Set(configs.RedisKeyPrefixAgent+"revoked", string(raw), 0); err != nil {
+		return nil, err
+	}
+
+	a.mux.Lock()
+	a.revoked, a.loadedAt = revoked, now
+	a.mux.Unlock()
+	return issued, nil
+}
+
+// CRL 生成 DER 格式的证书吊销列表
+func (a *Authority) CRL() ([]byte, error) {
+	revoked, err := a.revokedList(false)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]tlsutil.RevokedCertificate, 0, len(revoked.Entries))
+	for serial, entry := range revoked.Entries {
+		if n, ok := new(big.Int).SetString(serial, 16); ok {
+			list = append(list, tlsutil.RevokedCertificate{Serial: n, RevokedAt: entry.RevokedAt})
+		}
+	}
+	return a.ca.CRL(list, revoked.Number, crlValidity)
+}
+
+// revokedList 读取吊销列表，本地缓存 revokedRefresh 时长；fresh 为 true 时忽略缓存
+func (a *Authority) revokedList(fresh bool) (*revocations, error) {
+	a.mux.RLock()
+	cached, loadedAt := a.revoked, a.loadedAt
+	a.mux.RUnlock()
+	if !fresh && cached != nil && time.Since(loadedAt) < revokedRefresh {
+		return cached, nil
+	}
+
+	revoked := &revocations{Entries: make(map[string]*revokedEntry)}
+	key := configs.RedisKeyPrefixAgent + "revoked"
+	if a.cache.Exists(key) {
+		value, err := a.cache.Get(key)
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal([]byte(value), revoked); err != nil {
+			return nil, err
+		}
+		if revoked.Entries == nil {
+			revoked.Entries = make(map[string]*revokedEntry)
+		}
+	}
+
+	a.mux.Lock()
+	a.revoked, a.loadedAt = revoked, time.Now()
+	a.mux.Unlock()
+	return revoked, nil
+}
+
+// ServerTLSConfig mTLS 端口的 TLS 配置：服务端证书由内置 CA 签发，要求客户端提供由内置 CA 签发的证书
+func (a *Authority) ServerTLSConfig(hosts []string, minVersion uint16) (*tls.Config, error) {
+	cert, err := a.ca.IssueServer(hosts, serverCertValidity)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion:   minVersion,
+		Certificates: []tls.Certificate{*cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    a.ca.Pool(),
+	}, nil
+}
+
+// issuedKey 签发记录的 Key，serial 为小写十六进制
+func issuedKey(serial string) string {
+	return configs.RedisKeyPrefixAgent + "cert:" + serial
+}
+
+// enrollmentKey 注册码仅保存其哈希
+func enrollmentKey(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return configs.RedisKeyPrefixAgent + "enroll:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,272 @@
+package agent
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/kisun-bit/aio_dashboard/configs"
+	"github.com/kisun-bit/aio_dashboard/internal/depends/redis"
+	"github.com/kisun-bit/aio_dashboard/internal/proposal"
+	"github.com/kisun-bit/aio_dashboard/pkg/tlsutil"
+)
+
+func newTestAuthority(t *testing.T) (*miniredis.Miniredis, *Authority) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	cache, err := redis.Dial(server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = cache.Close() })
+
+	dir := t.TempDir()
+	ca, err := tlsutil.LoadOrCreateCA(filepath.Join(dir, "ca.crt"), filepath.Join(dir, "ca.key"), "Test CA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, New(ca, cache, WithCertValidity(time.Hour))
+}
+
+func newCSR(t *testing.T) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: pkix.Name{CommonName: "agent"}}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})
+}
+
+func enroll(t *testing.T, a *Authority, agentID string) (*Certificate, *x509.Certificate) {
+	t.Helper()
+
+	code, err := a.CreateEnrollmentCode(agentID, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := a.Enroll(code.Code, newCSR(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode([]byte(cert.Certificate))
+	parsed, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, parsed
+}
+
+func TestEnrollmentCodeSingleUse(t *testing.T) {
+	_, a := newTestAuthority(t)
+
+	code, err := a.CreateEnrollmentCode("", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := a.Enroll(code.Code, newCSR(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.AgentID != code.AgentID {
+		t.Errorf("agent id = %q, want %q", cert.AgentID, code.AgentID)
+	}
+	if _, err = a.Enroll(code.Code, newCSR(t)); !errors.Is(err, ErrEnrollmentCode) {
+		t.Errorf("reuse err = %v, want ErrEnrollmentCode", err)
+	}
+	if _, err = a.Enroll("UNKNOWN", newCSR(t)); !errors.Is(err, ErrEnrollmentCode) {
+		t.Errorf("unknown err = %v, want ErrEnrollmentCode", err)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	server, a := newTestAuthority(t)
+	issued, leaf := enroll(t, a, "agent-1")
+
+	tests := []struct {
+		name    string
+		serial  string
+		wantErr error
+	}{
+		{name: "invalid serial", serial: "xyz", wantErr: ErrInvalidSerial},
+		{name: "never issued", serial: "abcdef", wantErr: ErrUnknownCertificate},
+		{name: "issued", serial: " " + issued.Serial + " "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record, err := a.Revoke(tt.serial)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if record.AgentID != "agent-1" || !record.NotAfter.Equal(issued.NotAfter) {
+				t.Errorf("record = %+v, want not after %v from the issued certificate", record, issued.NotAfter)
+			}
+		})
+	}
+
+	if _, err := a.Identify(leaf); !errors.Is(err, ErrRevoked) {
+		t.Errorf("identify revoked err = %v", err)
+	}
+	if server.Exists(configs.RedisKeyPrefixAgent + "revoked:lock") {
+		t.Error("revocation lock not released")
+	}
+
+	der, err := a.CRL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	crl, err := x509.ParseRevocationList(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(crl.RevokedCertificates) != 1 || crl.RevokedCertificates[0].SerialNumber.Cmp(leaf.SerialNumber) != 0 {
+		t.Errorf("crl entries = %v", crl.RevokedCertificates)
+	}
+}
+
+func TestRevokeExpiredCertificate(t *testing.T) {
+	server, a := newTestAuthority(t)
+	issued, _ := enroll(t, a, "agent-1")
+
+	server.FastForward(time.Hour + time.Minute)
+	if _, err := a.Revoke(issued.Serial); !errors.Is(err, ErrUnknownCertificate) {
+		t.Errorf("revoke expired err = %v, want ErrUnknownCertificate", err)
+	}
+}
+
+func TestRevokeWhileLocked(t *testing.T) {
+	server, a := newTestAuthority(t)
+	issued, leaf := enroll(t, a, "agent-1")
+
+	lockKey := configs.RedisKeyPrefixAgent + "revoked:lock"
+	if err := server.Set(lockKey, "other"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Revoke(issued.Serial); err == nil {
+		t.Fatal("want error while another revocation holds the lock")
+	}
+	if got, _ := server.Get(lockKey); got != "other" {
+		t.Errorf("lock of another owner = %q, must not be released", got)
+	}
+	if _, err := a.Identify(leaf); err != nil {
+		t.Errorf("identify err = %v, certificate should not be revoked", err)
+	}
+}
+
+// failingCache 保存签发记录时返回错误，用于模拟注册码删除之后的签发失败
+type failingCache struct {
+	redis.Operator
+}
+
+func (c failingCache) Set(key, value string, ttl time.Duration, options ...redis.Option) error {
+	if strings.HasPrefix(key, configs.RedisKeyPrefixAgent+"cert:") {
+		return errors.New("cache unavailable")
+	}
+	return c.Operator.Set(key, value, ttl, options...)
+}
+
+func TestEnrollKeepsCodeOnFailure(t *testing.T) {
+	server, a := newTestAuthority(t)
+
+	code, err := a.CreateEnrollmentCode("agent-1", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, csr := range map[string][]byte{
+		"not pem":        []byte("csr"),
+		"broken":         pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: []byte{1}}),
+		"wrong pem type": pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte{1}}),
+	} {
+		if _, err = a.Enroll(code.Code, csr); !errors.Is(err, ErrInvalidCSR) {
+			t.Errorf("%s: err = %v, want ErrInvalidCSR", name, err)
+		}
+	}
+
+	cache := a.cache
+	a.cache = failingCache{Operator: cache}
+	if _, err = a.Enroll(code.Code, newCSR(t)); err == nil || errors.Is(err, ErrEnrollmentCode) {
+		t.Fatalf("err = %v, want the issue failure", err)
+	}
+	a.cache = cache
+
+	key := enrollmentKey(code.Code)
+	if ttl := server.TTL(key); ttl <= 0 || ttl > 24*time.Hour {
+		t.Errorf("restored code ttl = %v, want the remaining ttl", ttl)
+	}
+	cert, err := a.Enroll(code.Code, newCSR(t))
+	if err != nil {
+		t.Fatalf("code should still be usable: %v", err)
+	}
+	if cert.AgentID != "agent-1" {
+		t.Errorf("agent id = %q", cert.AgentID)
+	}
+}
+
+func TestRenewRevokesCurrentCertificate(t *testing.T) {
+	_, a := newTestAuthority(t)
+	_, leaf := enroll(t, a, "agent-1")
+
+	current, err := a.Identify(leaf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = a.Renew(current, []byte("csr")); !errors.Is(err, ErrInvalidCSR) {
+		t.Fatalf("invalid csr err = %v, want ErrInvalidCSR", err)
+	}
+	if _, err = a.Identify(leaf); err != nil {
+		t.Fatalf("current certificate revoked by a rejected renewal: %v", err)
+	}
+
+	renewed, err := a.Renew(current, newCSR(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renewed.AgentID != "agent-1" || renewed.Serial == current.Serial {
+		t.Errorf("renewed = %+v", renewed)
+	}
+	if _, err = a.Identify(leaf); !errors.Is(err, ErrRevoked) {
+		t.Errorf("previous certificate err = %v, want ErrRevoked", err)
+	}
+
+	// 已吊销的证书不能再次续期，本次签发的证书随之作废
+	if _, err = a.Renew(current, newCSR(t)); !errors.Is(err, ErrRevoked) {
+		t.Fatalf("renew with revoked certificate err = %v, want ErrRevoked", err)
+	}
+	revoked, err := a.revokedList(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revoked.Entries) != 2 {
+		t.Errorf("revoked %d certificates, want the previous one and the discarded renewal", len(revoked.Entries))
+	}
+	if _, ok := revoked.Entries[renewed.Serial]; ok {
+		t.Error("the valid renewed certificate must not be revoked")
+	}
+
+	if _, err = a.Renew(proposal.AgentIdentity{AgentID: "agent-1", Serial: renewed.Serial}, newCSR(t)); err != nil {
+		t.Errorf("renew with the renewed certificate: %v", err)
+	}
+}
@@ -0,0 +1,216 @@
+package alert
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kisun-bit/aio_dashboard/internal/proposal"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultDedupeWindow 相同告警(业务码+堆栈指纹)的去重时间窗口
+	DefaultDedupeWindow = time.Minute * 10
+
+	// DefaultMaxPerMinute 每分钟最多发送的告警数量
+	DefaultMaxPerMinute = 20
+
+	// DefaultQueueSize 待发送告警队列长度
+	DefaultQueueSize = 128
+)
+
+// Notifier 告警通知渠道
+type Notifier interface {
+	// Name 渠道名称
+	Name() string
+	// Notify 发送告警
+	Notify(msg *proposal.AlertMessage) error
+}
+
+// Option 自定义 Dispatcher 配置
+type Option func(*option)
+
+type option struct {
+	projectName  string
+	dedupeWindow time.Duration
+	maxPerMinute int
+	queueSize    int
+}
+
+// WithProjectName 设置告警中的项目名称
+func WithProjectName(name string) Option {
+	return func(opt *option) {
+		opt.projectName = name
+	}
+}
+
+// WithDedupeWindow 设置去重时间窗口，窗口内相同的告警只发送一次
+func WithDedupeWindow(window time.Duration) Option {
+	return func(opt *option) {
+		if window > 0 {
+			opt.dedupeWindow = window
+		}
+	}
+}
+
+// WithMaxPerMinute 设置每分钟最多发送的告警数量
+func WithMaxPerMinute(n int) Option {
+	return func(opt *option) {
+		if n > 0 {
+			opt.maxPerMinute = n
+		}
+	}
+}
+
+// Dispatcher 告警分发器，对告警去重、限流后异步发送至所有 Notifier
+type Dispatcher struct {
+	logger    *zap.Logger
+	opt       *option
+	notifiers []Notifier
+
+	mux    sync.Mutex
+	sent   map[string]time.Time // 指纹 -> 最近一次发送时间
+	tokens float64
+	last   time.Time
+	closed bool
+
+	queue chan *proposal.AlertMessage
+	done  chan struct{}
+}
+
+// New 创建告警分发器
+func New(logger *zap.Logger, notifiers []Notifier, options ...Option) *Dispatcher {
+	opt := &option{
+		dedupeWindow: DefaultDedupeWindow,
+		maxPerMinute: DefaultMaxPerMinute,
+		queueSize:    DefaultQueueSize,
+	}
+	for _, f := range options {
+		f(opt)
+	}
+
+	d := &Dispatcher{
+		logger:    logger,
+		opt:       opt,
+		notifiers: notifiers,
+		sent:      make(map[string]time.Time),
+		tokens:    float64(opt.maxPerMinute),
+		last:      time.Now(),
+		queue:     make(chan *proposal.AlertMessage, opt.queueSize),
+		done:      make(chan struct{}),
+	}
+	go d.loop()
+
+	return d
+}
+
+// Notify 提交告警，满足 proposal.NotifyHandler
+func (d *Dispatcher) Notify(msg *proposal.AlertMessage) {
+	if msg == nil || len(d.notifiers) == 0 {
+		return
+	}
+	if msg.ProjectName == "" {
+		msg.ProjectName = d.opt.projectName
+	}
+
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	if d.closed || !d.allow(Fingerprint(msg), msg.Timestamp) {
+		return
+	}
+
+	select {
+	case d.queue <- msg:
+	default:
+		d.logger.Warn("alert queue is full, drop message", zap.String("trace_id", msg.TraceID))
+	}
+}
+
+// Close 停止接收新告警，并等待队列中的告警发送完毕
+func (d *Dispatcher) Close() {
+	d.mux.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.mux.Unlock()
+
+	<-d.done
+}
+
+// allow 判断告警是否需要发送：窗口内重复的告警直接丢弃，超出速率的告警同样丢弃；调用方需持有锁
+func (d *Dispatcher) allow(fingerprint string, now time.Time) bool {
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	for k, ts := range d.sent {
+		if now.Sub(ts) >= d.opt.dedupeWindow {
+			delete(d.sent, k)
+		}
+	}
+	if _, ok := d.sent[fingerprint]; ok {
+		return false
+	}
+
+	// 令牌桶：每分钟补充 maxPerMinute 个令牌
+	rate := float64(d.opt.maxPerMinute) / time.Minute.Seconds()
+	d.tokens += now.Sub(d.last).Seconds() * rate
+	if limit := float64(d.opt.maxPerMinute); d.tokens > limit {
+		d.tokens = limit
+	}
+	d.last = now
+	if d.tokens < 1 {
+		d.logger.Warn("alert rate limited", zap.String("fingerprint", fingerprint))
+		return false
+	}
+
+	d.tokens--
+	d.sent[fingerprint] = now
+	return true
+}
+
+func (d *Dispatcher) loop() {
+	defer close(d.done)
+
+	for msg := range d.queue {
+		for _, n := range d.notifiers {
+			if err := n.Notify(msg); err != nil {
+				d.logger.Error("send alert",
+					zap.String("notifier", n.Name()),
+					zap.String("trace_id", msg.TraceID),
+					zap.Error(err),
+				)
+			}
+		}
+	}
+}
+
+var (
+	stackFrameRegexp = regexp.MustCompile(`\.go:\d+`)
+	stackAddrRegexp  = regexp.MustCompile(`\s*\+0x[0-9a-f]+|\(0x[0-9a-f, .]*\)`)
+)
+
+// Fingerprint 根据业务码及堆栈帧(忽略地址、参数等易变信息)计算告警指纹
+func Fingerprint(msg *proposal.AlertMessage) string {
+	var frames []string
+	for _, line := range strings.Split(msg.ErrorStack, "\n") {
+		if stackFrameRegexp.MatchString(line) {
+			frames = append(frames, strings.TrimSpace(stackAddrRegexp.ReplaceAllString(line, "")))
+		}
+	}
+
+	basis := strings.Join(frames, "\n")
+	if basis == "" {
+		basis = msg.ErrorMessage
+	}
+
+	sum := sha1.Sum([]byte(fmt.Sprintf("%d|%s", msg.BusinessCode, basis)))
+	return hex.EncodeToString(sum[:])
+}
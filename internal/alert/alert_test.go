@@ -0,0 +1,150 @@
+package alert
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kisun-bit/aio_dashboard/internal/proposal"
+	"go.uber.org/zap"
+)
+
+// recorder 记录收到的告警，block 不为空时每次发送前等待其放行
+type recorder struct {
+	mux      sync.Mutex
+	msgs     []*proposal.AlertMessage
+	started  chan struct{}
+	block    chan struct{}
+	failWith error
+}
+
+func (r *recorder) Name() string {
+	return "recorder"
+}
+
+func (r *recorder) Notify(msg *proposal.AlertMessage) error {
+	if r.started != nil {
+		r.started <- struct{}{}
+	}
+	if r.block != nil {
+		<-r.block
+	}
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.msgs = append(r.msgs, msg)
+	return r.failWith
+}
+
+func (r *recorder) count() int {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return len(r.msgs)
+}
+
+func newMessage(businessCode int, stack string, ts time.Time) *proposal.AlertMessage {
+	return &proposal.AlertMessage{
+		TraceID:      strconv.FormatInt(ts.UnixNano(), 10),
+		BusinessCode: businessCode,
+		ErrorMessage: "boom",
+		ErrorStack:   stack,
+		Timestamp:    ts,
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	stack := "goroutine 1 [running]:\nmain.handler(0xc000010000, 0x1)\n\t/app/handler.go:42 +0x1d\n"
+	moved := "goroutine 7 [running]:\nmain.handler(0xc000ff0000, 0x2)\n\t/app/handler.go:42 +0x2f\n"
+
+	tests := []struct {
+		name string
+		a, b *proposal.AlertMessage
+		same bool
+	}{
+		{"ignore addresses and args", newMessage(10101, stack, time.Time{}), newMessage(10101, moved, time.Time{}), true},
+		{"different business code", newMessage(10101, stack, time.Time{}), newMessage(10102, stack, time.Time{}), false},
+		{"different frame", newMessage(10101, stack, time.Time{}), newMessage(10101, "\t/app/other.go:7 +0x1\n", time.Time{}), false},
+		{"fallback to message", &proposal.AlertMessage{ErrorMessage: "a"}, &proposal.AlertMessage{ErrorMessage: "b"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Fingerprint(tt.a) == Fingerprint(tt.b); got != tt.same {
+				t.Errorf("same fingerprint = %v, want %v", got, tt.same)
+			}
+		})
+	}
+}
+
+func TestDispatcherDedupe(t *testing.T) {
+	r := new(recorder)
+	d := New(zap.NewNop(), []Notifier{r}, WithDedupeWindow(time.Minute), WithMaxPerMinute(100))
+
+	now := time.Now()
+	d.Notify(newMessage(10101, "\t/app/a.go:1\n", now))
+	d.Notify(newMessage(10101, "\t/app/a.go:1\n", now.Add(30*time.Second)))          // 窗口内重复
+	d.Notify(newMessage(10102, "\t/app/a.go:1\n", now.Add(30*time.Second)))          // 业务码不同
+	d.Notify(newMessage(10101, "\t/app/a.go:1\n", now.Add(time.Minute+time.Second))) // 窗口过后
+	d.Close()
+
+	if got := r.count(); got != 3 {
+		t.Fatalf("sent %d alerts, want 3", got)
+	}
+}
+
+func TestDispatcherThrottle(t *testing.T) {
+	r := new(recorder)
+	d := New(zap.NewNop(), []Notifier{r}, WithMaxPerMinute(2))
+
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		d.Notify(newMessage(10101+i, "", now))
+	}
+	// 令牌按每分钟 2 个补充，30 秒后补充 1 个
+	d.Notify(newMessage(20101, "", now.Add(30*time.Second)))
+	d.Notify(newMessage(20102, "", now.Add(30*time.Second)))
+	d.Close()
+
+	if got := r.count(); got != 3 {
+		t.Fatalf("sent %d alerts, want 3", got)
+	}
+}
+
+func TestDispatcherQueueOverflow(t *testing.T) {
+	r := &recorder{started: make(chan struct{}, 4), block: make(chan struct{})}
+	d := New(zap.NewNop(), []Notifier{r}, WithMaxPerMinute(100), func(opt *option) { opt.queueSize = 1 })
+
+	now := time.Now()
+	d.Notify(newMessage(10101, "", now))
+	<-r.started // 第一条已出队，正在发送
+
+	d.Notify(newMessage(10102, "", now)) // 进入队列
+	d.Notify(newMessage(10103, "", now)) // 队列已满，丢弃
+
+	close(r.block)
+	d.Close()
+
+	if got := r.count(); got != 2 {
+		t.Fatalf("sent %d alerts, want 2", got)
+	}
+}
+
+func TestDispatcherCloseDrainsAndRejects(t *testing.T) {
+	failed := &recorder{failWith: errors.New("unavailable")}
+	ok := new(recorder)
+	d := New(zap.NewNop(), []Notifier{failed, ok}, WithProjectName("dashboard"))
+
+	msg := newMessage(10101, "", time.Now())
+	d.Notify(msg)
+	d.Close()
+	d.Notify(newMessage(10102, "", time.Now()))
+
+	if ok.count() != 1 || failed.count() != 1 {
+		t.Fatalf("sent %d/%d alerts, want 1/1", ok.count(), failed.count())
+	}
+	if msg.ProjectName != "dashboard" {
+		t.Errorf("project name = %q, want dashboard", msg.ProjectName)
+	}
+}
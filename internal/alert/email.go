@@ -0,0 +1,68 @@
+package alert
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kisun-bit/aio_dashboard/internal/proposal"
+)
+
+var _ Notifier = (*EmailNotifier)(nil)
+
+// EmailNotifier 通过 SMTP 发送告警邮件
+type EmailNotifier struct {
+	addr string
+	auth smtp.Auth
+	from string
+	to   []string
+}
+
+// NewEmailNotifier 创建邮件告警渠道；user 为空时不进行 SMTP 认证
+func NewEmailNotifier(host string, port int, user, password, from string, to []string) *EmailNotifier {
+	n := &EmailNotifier{
+		addr: net.JoinHostPort(host, strconv.Itoa(port)),
+		from: from,
+		to:   to,
+	}
+	if user != "" {
+		n.auth = smtp.PlainAuth("", user, password, host)
+	}
+	return n
+}
+
+func (n *EmailNotifier) Name() string {
+	return "email"
+}
+
+func (n *EmailNotifier) Notify(msg *proposal.AlertMessage) error {
+	if len(n.to) == 0 {
+		return nil
+	}
+
+	subject := fmt.Sprintf("[%s][%s] 系统告警 %d %s %s",
+		msg.ProjectName, msg.Env, msg.BusinessCode, msg.Method, msg.URI)
+
+	body := new(bytes.Buffer)
+	fmt.Fprintf(body, "From: %s\r\n", n.from)
+	fmt.Fprintf(body, "To: %s\r\n", strings.Join(n.to, ","))
+	fmt.Fprintf(body, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(body, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(body, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(body, "Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	fmt.Fprintf(body, "项目: %s\r\n", msg.ProjectName)
+	fmt.Fprintf(body, "环境: %s\r\n", msg.Env)
+	fmt.Fprintf(body, "TraceID: %s\r\n", msg.TraceID)
+	fmt.Fprintf(body, "请求: %s %s%s\r\n", msg.Method, msg.HOST, msg.URI)
+	fmt.Fprintf(body, "业务码: %d\r\n", msg.BusinessCode)
+	fmt.Fprintf(body, "时间: %s\r\n", msg.Timestamp.Format(time.RFC3339))
+	fmt.Fprintf(body, "错误信息: %s\r\n\r\n", msg.ErrorMessage)
+	fmt.Fprintf(body, "堆栈信息:\r\n%s\r\n", strings.ReplaceAll(msg.ErrorStack, "\n", "\r\n"))
+
+	return smtp.SendMail(n.addr, n.auth, n.from, n.to, body.Bytes())
+}
@@ -0,0 +1,44 @@
+package alert
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/kisun-bit/aio_dashboard/internal/proposal"
+)
+
+var _ Notifier = (*FileNotifier)(nil)
+
+// FileNotifier 将告警以 JSON Lines 形式追加至本地文件
+type FileNotifier struct {
+	mux  sync.Mutex
+	path string
+}
+
+// NewFileNotifier 创建本地文件告警渠道
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{path: path}
+}
+
+func (n *FileNotifier) Name() string {
+	return "file"
+}
+
+func (n *FileNotifier) Notify(msg *proposal.AlertMessage) error {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(n.path), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(n.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(msg.Marshal(), '\n'))
+	return err
+}
@@ -0,0 +1,203 @@
+package alert
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kisun-bit/aio_dashboard/internal/proposal"
+)
+
+func testMessage() *proposal.AlertMessage {
+	return &proposal.AlertMessage{
+		ProjectName:  "dashboard",
+		Env:          "fat",
+		TraceID:      "trace-1",
+		HOST:         "127.0.0.1:9999",
+		URI:          "/api/demo",
+		Method:       http.MethodPost,
+		BusinessCode: 10101,
+		ErrorMessage: "boom",
+		ErrorStack:   "main.handler()\n\t/app/handler.go:42",
+		Timestamp:    time.Now(),
+	}
+}
+
+// smtpMail SMTP 桩收到的邮件
+type smtpMail struct {
+	from string
+	to   []string
+	data string
+}
+
+// startSMTPStub 启动仅支持明文 SMTP 基本命令的桩服务，收到的邮件写入返回的 channel
+func startSMTPStub(t *testing.T) (string, int, <-chan smtpMail) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	mails := make(chan smtpMail, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		tp := textproto.NewConn(conn)
+		mail := smtpMail{}
+		_ = tp.PrintfLine("220 stub ESMTP")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch {
+			case cmd == "EHLO" || cmd == "HELO":
+				_ = tp.PrintfLine("250 stub")
+			case strings.HasPrefix(strings.ToUpper(line), "MAIL FROM:"):
+				mail.from = strings.Trim(line[len("MAIL FROM:"):], "<>")
+				_ = tp.PrintfLine("250 OK")
+			case strings.HasPrefix(strings.ToUpper(line), "RCPT TO:"):
+				mail.to = append(mail.to, strings.Trim(line[len("RCPT TO:"):], "<>"))
+				_ = tp.PrintfLine("250 OK")
+			case cmd == "DATA":
+				_ = tp.PrintfLine("354 go ahead")
+				data, err := tp.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				mail.data = string(data)
+				_ = tp.PrintfLine("250 OK")
+			case cmd == "QUIT":
+				_ = tp.PrintfLine("221 bye")
+				mails <- mail
+				return
+			default:
+				_ = tp.PrintfLine("502 not implemented")
+			}
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return host, p, mails
+}
+
+func TestEmailNotifier(t *testing.T) {
+	host, port, mails := startSMTPStub(t)
+
+	n := NewEmailNotifier(host, port, "", "", "alert@example.com", []string{"ops@example.com", "dev@example.com"})
+	if err := n.Notify(testMessage()); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+
+	select {
+	case mail := <-mails:
+		if mail.from != "alert@example.com" {
+			t.Errorf("from = %q", mail.from)
+		}
+		if len(mail.to) != 2 {
+			t.Errorf("to = %v", mail.to)
+		}
+
+		msg, err := textproto.NewReader(bufio.NewReader(strings.NewReader(mail.data))).ReadMIMEHeader()
+		if err != nil {
+			t.Fatalf("parse header: %v", err)
+		}
+		subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Get("Subject"))
+		for _, want := range []string{"[dashboard][fat]", "10101", "/api/demo"} {
+			if !strings.Contains(subject, want) {
+				t.Errorf("subject %q missing %q", subject, want)
+			}
+		}
+		for _, want := range []string{"TraceID: trace-1", "/app/handler.go:42"} {
+			if !strings.Contains(mail.data, want) {
+				t.Errorf("body missing %q", want)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("smtp stub received no mail")
+	}
+}
+
+func TestEmailNotifierNoRecipients(t *testing.T) {
+	n := NewEmailNotifier("127.0.0.1", 1, "", "", "alert@example.com", nil)
+	if err := n.Notify(testMessage()); err != nil {
+		t.Fatalf("notify without recipients: %v", err)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{"ok", http.StatusOK, false},
+		{"accepted", http.StatusAccepted, false},
+		{"server error", http.StatusInternalServerError, true},
+		{"redirect", http.StatusMultipleChoices, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got proposal.AlertMessage
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+					t.Errorf("unexpected request %s %s", r.Method, r.Header.Get("Content-Type"))
+				}
+				_ = json.NewDecoder(r.Body).Decode(&got)
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			err := NewWebhookNotifier(server.URL, nil).Notify(testMessage())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.TraceID != "trace-1" || got.BusinessCode != 10101 {
+				t.Errorf("webhook payload = %+v", got)
+			}
+		})
+	}
+}
+
+func TestFileNotifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alert", "alert.log")
+	n := NewFileNotifier(path)
+
+	for i := 0; i < 2; i++ {
+		if err := n.Notify(testMessage()); err != nil {
+			t.Fatalf("notify: %v", err)
+		}
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	var msg proposal.AlertMessage
+	if err = json.Unmarshal([]byte(lines[1]), &msg); err != nil || msg.TraceID != "trace-1" {
+		t.Errorf("line = %s, err = %v", lines[1], err)
+	}
+}
@@ -0,0 +1,46 @@
+package alert
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/kisun-bit/aio_dashboard/internal/proposal"
+)
+
+var _ Notifier = (*WebhookNotifier)(nil)
+
+// WebhookNotifier 以 JSON 形式将告警 POST 至指定地址
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookNotifier 创建 Webhook 告警渠道；client 为空时使用 5 秒超时的默认 client
+func NewWebhookNotifier(url string, client *http.Client) *WebhookNotifier {
+	if client == nil {
+		client = &http.Client{Timeout: time.Second * 5}
+	}
+	return &WebhookNotifier{url: url, client: client}
+}
+
+func (n *WebhookNotifier) Name() string {
+	return "webhook"
+}
+
+func (n *WebhookNotifier) Notify(msg *proposal.AlertMessage) error {
+	resp, err := n.client.Post(n.url, "application/json; charset=utf-8", bytes.NewReader(msg.Marshal()))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package code
+
+import "net/http"
+
+// 业务码约定：0 表示成功，其余为 5 位数字，前 3 位为模块，后 2 位为具体错误；
+// 各模块的业务码须通过 Register 登记至目录，重复的业务码在启动检查(Check)时报错
+const (
+	// OK 成功
+	OK = 0
+
+	// ServerError 服务内部错误
+	ServerError = 10101
+
+	// RequestTimeout 请求处理超时
+	RequestTimeout = 10102
+
+	// ParamBindError 参数信息错误
+	ParamBindError = 10103
+
+	// AuthorizationError 登录验证失败
+	AuthorizationError = 10104
+
+	// RequestEntityTooLarge 请求体过大
+	RequestEntityTooLarge = 10105
+
+	// FileNotFound 文件不存在
+	FileNotFound = 10106
+
+	// UploadNotFound 上传任务不存在
+	UploadNotFound = 10107
+
+	// UploadOffsetMismatch 分片偏移与已上传的进度不一致
+	UploadOffsetMismatch = 10108
+
+	// TooManyRequests 请求过于频繁，已被限流
+	TooManyRequests = 10109
+
+	// IdempotencyInFlight 相同 Idempotency-Key 的请求正在处理
+	IdempotencyInFlight = 10110
+
+	// IdempotencyKeyReused Idempotency-Key 已被请求内容不同的请求使用
+	IdempotencyKeyReused = 10111
+
+	// AgentCertificateError 备份代理的客户端证书缺失、无效或已吊销
+	AgentCertificateError = 10112
+
+	// EnrollmentCodeInvalid 注册码无效、已过期或已使用
+	EnrollmentCodeInvalid = 10113
+
+	// PermissionDenied 已登录但没有操作权限
+	PermissionDenied = 10114
+
+	// CertificateNotFound 证书不是由内置 CA 签发或已过期
+	CertificateNotFound = 10115
+
+	// RouteNotFound 请求的接口不存在
+	RouteNotFound = 10116
+)
+
+func init() {
+	Register(OK, http.StatusOK, "成功", "OK")
+	Register(ServerError, http.StatusInternalServerError, "服务内部错误", "Internal server error")
+	Register(RequestTimeout, http.StatusGatewayTimeout, "请求处理超时", "Request timed out")
+	Register(ParamBindError, http.StatusBadRequest, "参数信息错误", "Invalid parameters")
+	Register(AuthorizationError, http.StatusUnauthorized, "登录验证失败", "Authentication failed")
+	Register(RequestEntityTooLarge, http.StatusRequestEntityTooLarge, "请求体过大", "Request body too large")
+	Register(FileNotFound, http.StatusNotFound, "文件不存在", "File not found")
+	Register(UploadNotFound, http.StatusNotFound, "上传任务不存在", "Upload not found")
+	Register(UploadOffsetMismatch, http.StatusConflict, "分片偏移与上传进度不一致", "Upload offset mismatch")
+	Register(TooManyRequests, http.StatusTooManyRequests, "请求过于频繁，请稍后重试", "Too many requests, please retry later")
+	Register(IdempotencyInFlight, http.StatusConflict, "相同的请求正在处理，请稍后重试", "A request with the same idempotency key is in progress")
+	Register(IdempotencyKeyReused, http.StatusUnprocessableEntity, "幂等键已被不同的请求使用", "Idempotency key was used by a different request")
+	Register(AgentCertificateError, http.StatusUnauthorized, "代理证书验证失败", "Agent certificate verification failed")
+	Register(EnrollmentCodeInvalid, http.StatusForbidden, "注册码无效或已使用", "Enrollment code is invalid or already used")
+	Register(PermissionDenied, http.StatusForbidden, "没有操作权限", "Permission denied")
+	Register(CertificateNotFound, http.StatusNotFound, "证书不存在或已过期", "Certificate not found or expired")
+	Register(RouteNotFound, http.StatusNotFound, "接口不存在", "API not found")
+}
@@ -0,0 +1,123 @@
+package code
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kisun-bit/aio_dashboard/configs"
+)
+
+// Entry 业务码目录中的一项
+type Entry struct {
+	Code     int               `json:"code"`      // 业务码
+	HTTPCode int               `json:"http_code"` // HTTP 状态码
+	Messages map[string]string `json:"messages"`  // 语言 -> 描述信息
+}
+
+var catalog = struct {
+	mux        sync.RWMutex
+	entries    map[int]*Entry
+	duplicates []int
+}{
+	entries: make(map[int]*Entry),
+}
+
+// Register 登记业务码及其 HTTP 状态码与中英文描述，返回 code 以便于定义变量；
+// 重复登记不会覆盖已有的定义，由 Check 统一报告
+func Register(code, httpCode int, zhCN, enUS string) int {
+	catalog.mux.Lock()
+	defer catalog.mux.Unlock()
+
+	if _, ok := catalog.entries[code]; ok {
+		catalog.duplicates = append(catalog.duplicates, code)
+		return code
+	}
+
+	catalog.entries[code] = &Entry{
+		Code:     code,
+		HTTPCode: httpCode,
+		Messages: map[string]string{
+			configs.ZhCN: zhCN,
+			configs.EnUS: enUS,
+		},
+	}
+	return code
+}
+
+// Check 启动检查：业务码不可重复登记，且每种语言均须有描述信息
+func Check() error {
+	catalog.mux.RLock()
+	defer catalog.mux.RUnlock()
+
+	var problems []string
+	for _, code := range catalog.duplicates {
+		problems = append(problems, fmt.Sprintf("duplicate business code %d", code))
+	}
+	for _, entry := range catalog.entries {
+		for _, lang := range []string{configs.ZhCN, configs.EnUS} {
+			if entry.Messages[lang] == "" {
+				problems = append(problems, fmt.Sprintf("business code %d has no %s message", entry.Code, lang))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("check business codes: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Text 获取业务码对应的默认语言(简体中文)描述信息
+func Text(code int) string {
+	return Localize(code, configs.ZhCN)
+}
+
+// Localize 获取业务码对应 lang 语言的描述信息，缺失时回退至简体中文
+func Localize(code int, lang string) string {
+	catalog.mux.RLock()
+	defer catalog.mux.RUnlock()
+
+	entry, ok := catalog.entries[code]
+	if !ok {
+		return ""
+	}
+	if message := entry.Messages[lang]; message != "" {
+		return message
+	}
+	return entry.Messages[configs.ZhCN]
+}
+
+// HTTPCode 获取业务码对应的 HTTP 状态码，未登记时为 500
+func HTTPCode(code int) int {
+	catalog.mux.RLock()
+	defer catalog.mux.RUnlock()
+
+	if entry, ok := catalog.entries[code]; ok {
+		return entry.HTTPCode
+	}
+	return http.StatusInternalServerError
+}
+
+// All 按业务码顺序导出完整目录
+func All() []Entry {
+	catalog.mux.RLock()
+	defer catalog.mux.RUnlock()
+
+	entries := make([]Entry, 0, len(catalog.entries))
+	for _, entry := range catalog.entries {
+		messages := make(map[string]string, len(entry.Messages))
+		for lang, message := range entry.Messages {
+			messages[lang] = message
+		}
+		entries = append(entries, Entry{Code: entry.Code, HTTPCode: entry.HTTPCode, Messages: messages})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Code < entries[j].Code
+	})
+	return entries
+}
@@ -0,0 +1,147 @@
+package code
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/kisun-bit/aio_dashboard/configs"
+)
+
+// isolateCatalog 以空目录执行测试，结束后恢复包初始化时登记的业务码
+func isolateCatalog(t *testing.T) {
+	t.Helper()
+
+	catalog.mux.Lock()
+	entries, duplicates := catalog.entries, catalog.duplicates
+	catalog.entries, catalog.duplicates = make(map[int]*Entry), nil
+	catalog.mux.Unlock()
+
+	t.Cleanup(func() {
+		catalog.mux.Lock()
+		catalog.entries, catalog.duplicates = entries, duplicates
+		catalog.mux.Unlock()
+	})
+}
+
+func TestBuiltinCodesPassCheck(t *testing.T) {
+	if err := Check(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		register func()
+		wantErr  []string
+	}{
+		{
+			name:     "valid",
+			register: func() { Register(90001, http.StatusBadRequest, "错误", "Error") },
+		},
+		{
+			name: "duplicate",
+			register: func() {
+				Register(90001, http.StatusBadRequest, "错误", "Error")
+				Register(90001, http.StatusNotFound, "另一个", "Another")
+			},
+			wantErr: []string{"duplicate business code 90001"},
+		},
+		{
+			name:     "missing zh",
+			register: func() { Register(90002, http.StatusBadRequest, "", "Error") },
+			wantErr:  []string{"90002 has no " + configs.ZhCN},
+		},
+		{
+			name: "missing en and duplicate",
+			register: func() {
+				Register(90003, http.StatusBadRequest, "错误", "")
+				Register(90003, http.StatusBadRequest, "错误", "Error")
+			},
+			wantErr: []string{"duplicate business code 90003", "90003 has no " + configs.EnUS},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolateCatalog(t)
+			tt.register()
+
+			err := Check()
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("Check() = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("want error")
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Check() = %v, want %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDuplicateKeepsFirstDefinition(t *testing.T) {
+	isolateCatalog(t)
+	Register(90001, http.StatusBadRequest, "首次", "First")
+	Register(90001, http.StatusNotFound, "再次", "Second")
+
+	if got := HTTPCode(90001); got != http.StatusBadRequest {
+		t.Errorf("HTTPCode() = %d, want first definition", got)
+	}
+	if got := Localize(90001, configs.EnUS); got != "First" {
+		t.Errorf("Localize() = %q, want first definition", got)
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	isolateCatalog(t)
+	Register(90001, http.StatusBadRequest, "错误", "Error")
+	Register(90002, http.StatusBadRequest, "仅中文", "")
+
+	tests := []struct {
+		code int
+		lang string
+		want string
+	}{
+		{90001, configs.ZhCN, "错误"},
+		{90001, configs.EnUS, "Error"},
+		{90001, "fr-fr", "错误"},
+		{90001, "", "错误"},
+		{90002, configs.EnUS, "仅中文"},
+		{99999, configs.EnUS, ""},
+	}
+
+	for _, tt := range tests {
+		if got := Localize(tt.code, tt.lang); got != tt.want {
+			t.Errorf("Localize(%d, %q) = %q, want %q", tt.code, tt.lang, got, tt.want)
+		}
+	}
+	if got := Text(90001); got != "错误" {
+		t.Errorf("Text() = %q", got)
+	}
+	if got := HTTPCode(99999); got != http.StatusInternalServerError {
+		t.Errorf("HTTPCode(unregistered) = %d", got)
+	}
+}
+
+func TestAllSortedCopy(t *testing.T) {
+	isolateCatalog(t)
+	Register(90002, http.StatusBadRequest, "二", "Two")
+	Register(90001, http.StatusBadRequest, "一", "One")
+
+	entries := All()
+	if len(entries) != 2 || entries[0].Code != 90001 || entries[1].Code != 90002 {
+		t.Fatalf("All() = %+v", entries)
+	}
+	entries[0].Messages[configs.EnUS] = "changed"
+	if Localize(90001, configs.EnUS) != "One" {
+		t.Error("All() must return a copy")
+	}
+}
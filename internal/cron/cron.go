@@ -0,0 +1,73 @@
+package cron
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	robfig "github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+)
+
+// Job 后台任务，ctx 在停止服务时取消，正在运行的任务应据此保存进度并尽快返回
+type Job func(ctx context.Context, logger *zap.Logger)
+
+// Server 后台任务调度，任务的日志写入 cron 日志；同一任务上次未结束时跳过本次执行
+type Server struct {
+	logger *zap.Logger
+	cron   *robfig.Cron
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// New 创建后台任务调度，Start 后开始执行
+func New(logger *zap.Logger) *Server {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Server{
+		logger: logger,
+		cron: robfig.New(
+			robfig.WithSeconds(),
+			robfig.WithLogger(robfig.PrintfLogger(zap.NewStdLog(logger))),
+			robfig.WithChain(robfig.SkipIfStillRunning(robfig.PrintfLogger(zap.NewStdLog(logger)))),
+		),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// AddJob 按 spec(含秒，如 "0 */5 * * * *")添加任务，spec 非法时返回错误
+func (s *Server) AddJob(name, spec string, job Job) error {
+	logger := s.logger.With(zap.String("job", name))
+
+	_, err := s.cron.AddFunc(spec, func() {
+		defer func() {
+			if err := recover(); err != nil {
+				logger.Error("cron job panic", zap.String("panic", fmt.Sprintf("%+v", err)), zap.String("stack", string(debug.Stack())))
+			}
+		}()
+		job(s.ctx, logger)
+	})
+	if err != nil {
+		return fmt.Errorf("add cron job %s: %w", name, err)
+	}
+	return nil
+}
+
+// Start 在后台开始调度
+func (s *Server) Start() {
+	s.cron.Start()
+}
+
+// Checkpoint 停止调度并取消正在运行任务的 ctx，等待其保存进度后返回；ctx 到期时不再等待
+func (s *Server) Checkpoint(ctx context.Context) error {
+	stopped := s.cron.Stop()
+	s.cancel()
+
+	select {
+	case <-stopped.Done():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,94 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestAddJobInvalidSpec(t *testing.T) {
+	if err := New(zap.NewNop()).AddJob("bad", "* * *", func(context.Context, *zap.Logger) {}); err == nil {
+		t.Fatal("want error for invalid spec")
+	}
+}
+
+func TestCheckpointCancelsRunningJobs(t *testing.T) {
+	s := New(zap.NewNop())
+
+	started := make(chan struct{}, 1)
+	saved := make(chan struct{})
+	if err := s.AddJob("long", "* * * * * *", func(ctx context.Context, _ *zap.Logger) {
+		select {
+		case started <- struct{}{}:
+		default:
+			return
+		}
+		<-ctx.Done()
+		close(saved)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	s.Start()
+
+	select {
+	case <-started:
+	case <-time.After(3 * time.Second):
+		t.Fatal("job not started")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.Checkpoint(ctx); err != nil {
+		t.Fatalf("Checkpoint() = %v", err)
+	}
+	select {
+	case <-saved:
+	default:
+		t.Fatal("Checkpoint returned before the job saved its progress")
+	}
+}
+
+func TestCheckpointDeadline(t *testing.T) {
+	s := New(zap.NewNop())
+
+	started := make(chan struct{})
+	if err := s.AddJob("stuck", "* * * * * *", func(context.Context, *zap.Logger) {
+		close(started)
+		select {}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	s.Start()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := s.Checkpoint(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Checkpoint() = %v, want deadline exceeded", err)
+	}
+}
+
+func TestJobPanicRecovered(t *testing.T) {
+	s := New(zap.NewNop())
+
+	done := make(chan struct{})
+	if err := s.AddJob("panic", "* * * * * *", func(context.Context, *zap.Logger) {
+		defer close(done)
+		panic("boom")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	s.Start()
+
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("job not run")
+	}
+	if err := s.Checkpoint(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,7 +1,15 @@
 package postgresql
 
 import (
+	"fmt"
+	"time"
+
+	"github.com/kisun-bit/aio_dashboard/configs"
+	"github.com/pkg/errors"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
 )
 
 type GetCloser interface {
@@ -10,3 +18,81 @@ type GetCloser interface {
 	DBRClose() error
 	DBWClose() error
 }
+
+var _ GetCloser = (*dbRepo)(nil)
+
+type dbRepo struct {
+	DbR *gorm.DB
+	DbW *gorm.DB
+}
+
+// New 根据配置建立读、写两个连接池，连接失败时返回错误
+func New() (GetCloser, error) {
+	dbr, err := dbConnect()
+	if err != nil {
+		return nil, err
+	}
+
+	dbw, err := dbConnect()
+	if err != nil {
+		if sqlDB, e := dbr.DB(); e == nil {
+			_ = sqlDB.Close()
+		}
+		return nil, err
+	}
+
+	return &dbRepo{DbR: dbr, DbW: dbw}, nil
+}
+
+func (d *dbRepo) GetDBForRead() *gorm.DB {
+	return d.DbR
+}
+
+func (d *dbRepo) GetDBForWrite() *gorm.DB {
+	return d.DbW
+}
+
+func (d *dbRepo) DBRClose() error {
+	sqlDB, err := d.DbR.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+func (d *dbRepo) DBWClose() error {
+	sqlDB, err := d.DbW.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+func dbConnect() (*gorm.DB, error) {
+	settings := configs.Settings.DB
+
+	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable TimeZone=Asia/Shanghai",
+		settings.Host, settings.Port, settings.User, settings.Password, settings.DB)
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		NamingStrategy: schema.NamingStrategy{SingularTable: true},
+		Logger:         logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("[db connection failed] Database name: %s", settings.DB))
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	// 设置连接池 用于设置最大打开的连接数，默认值为0表示不限制.设置最大的连接数，可以避免并发太高导致连接数据库出现too many connections的错误。
+	sqlDB.SetMaxOpenConns(settings.MaxOpenConn)
+	// 设置最大连接数 用于设置闲置的连接数.设置闲置的连接数则当开启的一个连接使用完成后可以放在池里等候下一次使用。
+	sqlDB.SetMaxIdleConns(settings.MaxIdleConn)
+	// 设置最大连接超时
+	sqlDB.SetConnMaxLifetime(time.Minute * time.Duration(settings.ConnMaxLifetime))
+
+	return db, nil
+}
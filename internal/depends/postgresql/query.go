@@ -0,0 +1,348 @@
+package postgresql
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Operator 过滤操作符
+type Operator string
+
+const (
+	OpEq    Operator = "eq"    // 等于，如 status:eq:1
+	OpNe    Operator = "ne"    // 不等于，如 status:ne:1
+	OpIn    Operator = "in"    // 属于，多个值以逗号分隔，如 status:in:1,2
+	OpLike  Operator = "like"  // 包含，如 name:like:db
+	OpRange Operator = "range" // 区间(闭区间)，以逗号分隔上下限，可省略其一，如 created_at:range:2023-01-01,
+)
+
+const (
+	defaultPageSize = 20
+	defaultMaxSize  = 100
+)
+
+// Query 列表查询参数，通过 ContextWrap.ShouldBindQuery 绑定，如：
+// ?page=2&size=20&sort=-created_at,name&filter=status:in:1,2&filter=name:like:db&total=true
+type Query struct {
+	Page   int      `form:"page" binding:"omitempty,min=1"` // 页码，从 1 开始；指定 cursor 时忽略
+	Size   int      `form:"size" binding:"omitempty,min=1"` // 每页数量
+	Cursor string   `form:"cursor"`                         // 游标，取自上一页返回的 next_cursor，用于 keyset 分页
+	Sort   string   `form:"sort"`                           // 排序字段，多个以逗号分隔，"-" 前缀表示降序
+	Filter []string `form:"filter"`                         // 过滤条件，格式为 字段:操作符:值
+	Total  bool     `form:"total"`                          // 是否返回总数
+}
+
+// Field 资源中允许查询的字段
+type Field struct {
+	Column    string     // 列名
+	Sortable  bool       // 是否可排序
+	Operators []Operator // 允许的过滤操作符，为空时不可过滤
+}
+
+// Resource 资源的查询白名单，每个列表接口声明一次，如 agents、jobs
+type Resource struct {
+	Fields      map[string]Field // 对外字段名 -> 字段
+	KeyField    string           // 唯一且可排序的字段(如 id)，始终作为最后的排序字段以保证分页稳定
+	DefaultSort string           // 未指定 sort 时的排序，格式同 Query.Sort
+	MaxSize     int              // 每页数量上限，默认 100
+}
+
+// Page 分页结果，作为 Payload 返回
+type Page struct {
+	Page       int         `json:"page,omitempty"`        // 页码，keyset 分页时为空
+	Size       int         `json:"size"`                  // 每页数量
+	Total      *int64      `json:"total,omitempty"`       // 总数，仅 total=true 时返回
+	NextCursor string      `json:"next_cursor,omitempty"` // 下一页游标，没有更多数据时为空
+	List       interface{} `json:"list"`                  // 数据
+}
+
+type sortField struct {
+	name   string
+	column string
+	desc   bool
+}
+
+// Plan 经白名单校验后的查询计划
+type Plan struct {
+	page    int
+	size    int
+	total   bool
+	sorts   []sortField
+	filters []clause.Expression
+	cursor  []interface{}
+}
+
+// Parse 按白名单校验查询参数，不在白名单中的字段或操作符返回错误，调用方应转换为参数错误
+func (r *Resource) Parse(q Query) (*Plan, error) {
+	maxSize := r.MaxSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxSize
+	}
+
+	p := &Plan{page: q.Page, size: q.Size, total: q.Total}
+	if p.page <= 0 {
+		p.page = 1
+	}
+	if p.size <= 0 {
+		p.size = defaultPageSize
+	}
+	if p.size > maxSize {
+		p.size = maxSize
+	}
+
+	var err error
+	if p.sorts, err = r.parseSort(q.Sort); err != nil {
+		return nil, err
+	}
+
+	for _, filter := range q.Filter {
+		expr, err := r.parseFilter(filter)
+		if err != nil {
+			return nil, err
+		}
+		p.filters = append(p.filters, expr)
+	}
+
+	if q.Cursor != "" {
+		if len(p.sorts) == 0 {
+			return nil, fmt.Errorf("cursor requires sort or key field")
+		}
+		if p.cursor, err = decodeCursor(q.Cursor, len(p.sorts)); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+func (r *Resource) parseSort(sort string) ([]sortField, error) {
+	if sort == "" {
+		sort = r.DefaultSort
+	}
+
+	var sorts []sortField
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(sort, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+
+		desc := strings.HasPrefix(item, "-")
+		name := strings.TrimPrefix(strings.TrimPrefix(item, "-"), "+")
+
+		field, ok := r.Fields[name]
+		if !ok || !field.Sortable {
+			return nil, fmt.Errorf("field %q is not sortable", name)
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		sorts = append(sorts, sortField{name: name, column: field.Column, desc: desc})
+	}
+
+	if r.KeyField != "" && !seen[r.KeyField] {
+		field, ok := r.Fields[r.KeyField]
+		if !ok {
+			return nil, fmt.Errorf("key field %q is not declared", r.KeyField)
+		}
+		sorts = append(sorts, sortField{name: r.KeyField, column: field.Column})
+	}
+	return sorts, nil
+}
+
+func (r *Resource) parseFilter(filter string) (clause.Expression, error) {
+	parts := strings.SplitN(filter, ":", 3)
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid filter %q, expect field:operator:value", filter)
+	}
+
+	name, op, value := parts[0], Operator(parts[1]), parts[2]
+	field, ok := r.Fields[name]
+	if !ok || !containsOperator(field.Operators, op) {
+		return nil, fmt.Errorf("filter %q is not allowed on field %q", op, name)
+	}
+
+	column := clause.Column{Name: field.Column}
+	switch op {
+	case OpEq:
+		return clause.Eq{Column: column, Value: value}, nil
+	case OpNe:
+		return clause.Neq{Column: column, Value: value}, nil
+	case OpIn:
+		values := make([]interface{}, 0)
+		for _, v := range strings.Split(value, ",") {
+			values = append(values, v)
+		}
+		return clause.IN{Column: column, Values: values}, nil
+	case OpLike:
+		return clause.Like{Column: column, Value: "%" + escapeLike(value) + "%"}, nil
+	case OpRange:
+		bounds := strings.SplitN(value, ",", 2)
+		if len(bounds) != 2 || (bounds[0] == "" && bounds[1] == "") {
+			return nil, fmt.Errorf("invalid range %q, expect lower,upper", value)
+		}
+
+		var exprs []clause.Expression
+		if bounds[0] != "" {
+			exprs = append(exprs, clause.Gte{Column: column, Value: bounds[0]})
+		}
+		if bounds[1] != "" {
+			exprs = append(exprs, clause.Lte{Column: column, Value: bounds[1]})
+		}
+		return clause.And(exprs...), nil
+	default:
+		return nil, fmt.Errorf("unknown operator %q", op)
+	}
+}
+
+// Scopes 过滤、排序及分页条件，用于 db.Scopes(plan.Scopes()...)
+func (p *Plan) Scopes() []func(*gorm.DB) *gorm.DB {
+	return []func(*gorm.DB) *gorm.DB{p.FilterScope, p.orderScope, p.pageScope}
+}
+
+// FilterScope 仅包含过滤条件，可用于统计
+func (p *Plan) FilterScope(db *gorm.DB) *gorm.DB {
+	if len(p.filters) == 0 {
+		return db
+	}
+	return db.Where(clause.And(p.filters...))
+}
+
+func (p *Plan) orderScope(db *gorm.DB) *gorm.DB {
+	for _, sort := range p.sorts {
+		db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: sort.column}, Desc: sort.desc})
+	}
+	return db
+}
+
+// pageScope 指定游标时使用 keyset 分页，否则使用 offset 分页；多取一条以判断是否有下一页
+func (p *Plan) pageScope(db *gorm.DB) *gorm.DB {
+	if p.cursor != nil {
+		db = db.Where(p.keyset())
+	} else {
+		db = db.Offset((p.page - 1) * p.size)
+	}
+	return db.Limit(p.size + 1)
+}
+
+// keyset 生成 (a > ?) OR (a = ? AND b > ?) ... 形式的条件，降序字段使用 <
+func (p *Plan) keyset() clause.Expression {
+	var ors []clause.Expression
+	for i, sort := range p.sorts {
+		var ands []clause.Expression
+		for j := 0; j < i; j++ {
+			ands = append(ands, clause.Eq{Column: clause.Column{Name: p.sorts[j].column}, Value: p.cursor[j]})
+		}
+
+		column := clause.Column{Name: sort.column}
+		if sort.desc {
+			ands = append(ands, clause.Lt{Column: column, Value: p.cursor[i]})
+		} else {
+			ands = append(ands, clause.Gt{Column: column, Value: p.cursor[i]})
+		}
+		ors = append(ors, clause.And(ands...))
+	}
+	return clause.Or(ors...)
+}
+
+// Find 执行查询并将结果写入 dest(结构体切片的指针)；db 通常为 GetDBForRead()，
+// 仅在 total=true 时额外执行一次 count
+func (p *Plan) Find(db *gorm.DB, dest interface{}) (*Page, error) {
+	if db.Statement.Model == nil {
+		db = db.Model(dest)
+	}
+
+	page := &Page{Size: p.size}
+	if p.cursor == nil {
+		page.Page = p.page
+	}
+
+	if p.total {
+		var total int64
+		if err := db.Session(&gorm.Session{}).Scopes(p.FilterScope).Count(&total).Error; err != nil {
+			return nil, err
+		}
+		page.Total = &total
+	}
+
+	result := db.Session(&gorm.Session{}).Scopes(p.Scopes()...).Find(dest)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	list := reflect.ValueOf(dest).Elem()
+	if list.Len() > p.size {
+		list.Set(list.Slice(0, p.size))
+
+		cursor, err := p.encodeCursor(result.Statement, list.Index(p.size-1))
+		if err != nil {
+			return nil, err
+		}
+		page.NextCursor = cursor
+	}
+
+	page.List = list.Interface()
+	return page, nil
+}
+
+// encodeCursor 以最后一条数据的排序字段值生成游标
+func (p *Plan) encodeCursor(stmt *gorm.Statement, last reflect.Value) (string, error) {
+	if stmt.Schema == nil {
+		return "", fmt.Errorf("keyset paging requires a model schema")
+	}
+
+	last = reflect.Indirect(last)
+	values := make([]interface{}, len(p.sorts))
+	for i, sort := range p.sorts {
+		field := stmt.Schema.LookUpField(sort.column)
+		if field == nil {
+			return "", fmt.Errorf("column %q is not found in model %s", sort.column, stmt.Schema.Name)
+		}
+		values[i], _ = field.ValueOf(stmt.Context, last)
+	}
+
+	raw, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func decodeCursor(cursor string, fields int) ([]interface{}, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	// 使用 json.Number 以避免大整数丢失精度
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	var values []interface{}
+	if err = decoder.Decode(&values); err != nil || len(values) != fields {
+		return nil, fmt.Errorf("invalid cursor, sort may have changed")
+	}
+	return values, nil
+}
+
+func containsOperator(ops []Operator, op Operator) bool {
+	for _, o := range ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+// escapeLike 转义 LIKE 中的通配符，使 like 操作符按包含匹配
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
@@ -0,0 +1,216 @@
+package postgresql
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+type agentRow struct {
+	ID        int64  `gorm:"column:id"`
+	Name      string `gorm:"column:name"`
+	Status    int    `gorm:"column:status"`
+	CreatedAt string `gorm:"column:created_at"`
+}
+
+var agentResource = &Resource{
+	Fields: map[string]Field{
+		"id":         {Column: "id", Sortable: true, Operators: []Operator{OpEq, OpIn}},
+		"name":       {Column: "name", Sortable: true, Operators: []Operator{OpEq, OpLike}},
+		"status":     {Column: "status", Operators: []Operator{OpEq, OpNe, OpIn}},
+		"created_at": {Column: "created_at", Sortable: true, Operators: []Operator{OpRange}},
+	},
+	KeyField:    "id",
+	DefaultSort: "-created_at",
+	MaxSize:     50,
+}
+
+// dryRunDB 仅生成 SQL，不连接数据库
+func dryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1 port=1"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestResourceParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     Query
+		wantErr   string
+		wantPage  int
+		wantSize  int
+		wantSorts []string
+	}{
+		{name: "defaults", query: Query{}, wantPage: 1, wantSize: defaultPageSize, wantSorts: []string{"-created_at", "id"}},
+		{name: "size clamped", query: Query{Page: 3, Size: 500}, wantPage: 3, wantSize: 50, wantSorts: []string{"-created_at", "id"}},
+		{name: "custom sort", query: Query{Sort: "name, -id"}, wantPage: 1, wantSize: defaultPageSize, wantSorts: []string{"name", "-id"}},
+		{name: "duplicate sort", query: Query{Sort: "+name,-name"}, wantPage: 1, wantSize: defaultPageSize, wantSorts: []string{"name", "id"}},
+		{name: "unsortable", query: Query{Sort: "status"}, wantErr: "not sortable"},
+		{name: "unknown sort", query: Query{Sort: "password"}, wantErr: "not sortable"},
+		{name: "malformed filter", query: Query{Filter: []string{"status"}}, wantErr: "field:operator:value"},
+		{name: "operator not allowed", query: Query{Filter: []string{"name:in:a,b"}}, wantErr: "not allowed"},
+		{name: "unknown filter field", query: Query{Filter: []string{"password:eq:1"}}, wantErr: "not allowed"},
+		{name: "empty range", query: Query{Filter: []string{"created_at:range:,"}}, wantErr: "invalid range"},
+		{name: "bad cursor", query: Query{Cursor: "%%%"}, wantErr: "invalid cursor"},
+		{name: "cursor of another sort", query: Query{Cursor: mustCursor(t, 1)}, wantErr: "sort may have changed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := agentResource.Parse(tt.query)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+
+			if plan.page != tt.wantPage || plan.size != tt.wantSize {
+				t.Errorf("page/size = %d/%d, want %d/%d", plan.page, plan.size, tt.wantPage, tt.wantSize)
+			}
+			var sorts []string
+			for _, s := range plan.sorts {
+				if s.desc {
+					sorts = append(sorts, "-"+s.name)
+				} else {
+					sorts = append(sorts, s.name)
+				}
+			}
+			if !reflect.DeepEqual(sorts, tt.wantSorts) {
+				t.Errorf("sorts = %v, want %v", sorts, tt.wantSorts)
+			}
+		})
+	}
+}
+
+func TestResourceParseUndeclaredKeyField(t *testing.T) {
+	r := &Resource{Fields: map[string]Field{"name": {Column: "name", Sortable: true}}, KeyField: "id"}
+	if _, err := r.Parse(Query{}); err == nil {
+		t.Fatal("want error for undeclared key field")
+	}
+}
+
+func TestPlanScopesSQL(t *testing.T) {
+	tests := []struct {
+		name  string
+		query Query
+		want  string
+	}{
+		{
+			name:  "offset paging",
+			query: Query{Page: 2, Size: 10},
+			want:  `SELECT * FROM "agent_rows" ORDER BY "created_at" DESC,"id" LIMIT 11 OFFSET 10`,
+		},
+		{
+			name:  "filters",
+			query: Query{Filter: []string{"status:in:1,2", "name:like:50%_off", "status:ne:3", "created_at:range:2023-01-01,"}},
+			want: `SELECT * FROM "agent_rows" WHERE ("status" IN ($1,$2) AND "name" LIKE $3 AND "status" <> $4 AND "created_at" >= $5) ` +
+				`ORDER BY "created_at" DESC,"id" LIMIT 21`,
+		},
+		{
+			name:  "keyset paging",
+			query: Query{Cursor: mustCursor(t, "2023-01-01", 42)},
+			want: `SELECT * FROM "agent_rows" WHERE ("created_at" < $1 OR ("created_at" = $2 AND "id" > $3)) ` +
+				`ORDER BY "created_at" DESC,"id" LIMIT 21`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := agentResource.Parse(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var rows []agentRow
+			stmt := dryRunDB(t).Scopes(plan.Scopes()...).Find(&rows).Statement
+			if got := stmt.SQL.String(); got != tt.want {
+				t.Errorf("sql =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLikeEscapesWildcards(t *testing.T) {
+	plan, err := agentResource.Parse(Query{Filter: []string{`name:like:50%_\`}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rows []agentRow
+	stmt := dryRunDB(t).Scopes(plan.FilterScope).Find(&rows).Statement
+	if got := stmt.Vars[0]; got != `%50\%\_\\%` {
+		t.Errorf("like value = %v", got)
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	plan, err := agentResource.Parse(Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := schema.Parse(&agentRow{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := agentRow{ID: 9007199254740993, CreatedAt: "2023-01-01"}
+
+	cursor, err := plan.encodeCursor(&gorm.Statement{Schema: s}, reflect.ValueOf(&last))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := decodeCursor(cursor, len(plan.sorts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values[0] != "2023-01-01" || values[1] != json.Number("9007199254740993") {
+		t.Errorf("decoded cursor = %#v", values)
+	}
+	if _, err = decodeCursor(cursor, 3); err == nil {
+		t.Error("want error when sort fields changed")
+	}
+}
+
+func TestEncodeCursorUnknownColumn(t *testing.T) {
+	plan := &Plan{sorts: []sortField{{name: "x", column: "missing"}}}
+	s, err := schema.Parse(&agentRow{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = plan.encodeCursor(&gorm.Statement{Schema: s}, reflect.ValueOf(agentRow{})); err == nil {
+		t.Fatal("want error for unknown column")
+	}
+	if _, err = plan.encodeCursor(&gorm.Statement{}, reflect.ValueOf(agentRow{})); err == nil {
+		t.Fatal("want error without schema")
+	}
+}
+
+func mustCursor(t *testing.T, values ...interface{}) string {
+	t.Helper()
+
+	raw, err := json.Marshal(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
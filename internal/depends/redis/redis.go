@@ -1,12 +1,60 @@
 package redis
 
-import "time"
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kisun-bit/aio_dashboard/configs"
+	"github.com/kisun-bit/aio_dashboard/pkg/timeutil"
+	"github.com/kisun-bit/aio_dashboard/pkg/trace"
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+)
 
 type Option func(*option)
 
+type Trace = trace.T
+
+type option struct {
+	Ctx   context.Context
+	Trace *trace.Trace
+	Redis *trace.Redis
+}
+
+// WithContext 使 Redis 操作遵循 ctx 的取消与时限(如 core.ContextWrap.RequestContext)
+func WithContext(ctx context.Context) Option {
+	return func(opt *option) {
+		opt.Ctx = ctx
+	}
+}
+
+// WithTrace 将 Redis 操作记录至 Trace
+func WithTrace(t Trace) Option {
+	return func(opt *option) {
+		if t != nil {
+			opt.Trace = t.(*trace.Trace)
+			opt.Redis = new(trace.Redis)
+		}
+	}
+}
+
+// PoolStats 连接池统计信息
+type PoolStats struct {
+	Hits       uint32 // 从连接池中获取到空闲连接的次数
+	Misses     uint32 // 连接池中没有空闲连接的次数
+	Timeouts   uint32 // 等待连接超时的次数
+	TotalConns uint32 // 连接总数
+	IdleConns  uint32 // 空闲连接数
+	StaleConns uint32 // 已移除的过期连接数
+}
+
 type Operator interface {
 	i()
 	Set(key, value string, ttl time.Duration, options ...Option) error
+	SetNX(key, value string, ttl time.Duration, options ...Option) (bool, error)
 	Get(key string, options ...Option) (string, error)
 	TTL(key string) (time.Duration, error)
 	Expire(key string, ttl time.Duration) bool
@@ -14,6 +62,222 @@ type Operator interface {
 	Del(key string, options ...Option) bool
 	Exists(keys ...string) bool
 	Incr(key string, options ...Option) int64
+	Eval(script *Script, keys []string, args ...interface{}) (interface{}, error)
 	Close() error
 	Version() string
+	PoolStats() *PoolStats
+}
+
+var _ Operator = (*cacheRepo)(nil)
+
+type cacheRepo struct {
+	client *redis.Client
+}
+
+// New 根据配置连接 Redis，连接失败时返回错误
+func New() (Operator, error) {
+	settings := configs.Settings.Cache
+
+	db, err := strconv.Atoi(settings.DB)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid redis db %q", settings.DB)
+	}
+
+	return connect(&redis.Options{
+		Addr:         net.JoinHostPort(settings.Host, settings.Port),
+		Password:     settings.Password,
+		DB:           db,
+		MaxRetries:   settings.MaxRetries,
+		PoolSize:     settings.PoolSize,
+		MinIdleConns: settings.MinIdleConn,
+	})
+}
+
+// Dial 以默认的连接池配置连接 addr 上的 Redis(db 0)，不读取配置，供工具及测试使用
+func Dial(addr string) (Operator, error) {
+	return connect(&redis.Options{Addr: addr})
+}
+
+func connect(options *redis.Options) (Operator, error) {
+	client := redis.NewClient(options)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, errors.Wrap(err, "ping redis err")
+	}
+
+	return &cacheRepo{client: client}, nil
+}
+
+func (c *cacheRepo) i() {}
+
+// Set 设置 Key，ttl 为 0 时不过期
+func (c *cacheRepo) Set(key, value string, ttl time.Duration, options ...Option) error {
+	opt, ts := newOption(options...)
+	defer opt.record(ts, "set", key, value, ttl)
+
+	if err := c.client.Set(opt.Ctx, key, value, ttl).Err(); err != nil {
+		return errors.Wrapf(err, "redis set key: %s err", key)
+	}
+	return nil
+}
+
+// SetNX Key 不存在时设置 Key，已存在时返回 false；ttl 为 0 时不过期
+func (c *cacheRepo) SetNX(key, value string, ttl time.Duration, options ...Option) (bool, error) {
+	opt, ts := newOption(options...)
+	defer opt.record(ts, "setnx", key, value, ttl)
+
+	ok, err := c.client.SetNX(opt.Ctx, key, value, ttl).Result()
+	if err != nil {
+		return false, errors.Wrapf(err, "redis setnx key: %s err", key)
+	}
+	return ok, nil
+}
+
+// Get 读取 Key，Key 不存在时返回错误
+func (c *cacheRepo) Get(key string, options ...Option) (string, error) {
+	opt, ts := newOption(options...)
+
+	value, err := c.client.Get(opt.Ctx, key).Result()
+	opt.record(ts, "get", key, value, 0)
+	if err != nil {
+		return "", errors.Wrapf(err, "redis get key: %s err", key)
+	}
+	return value, nil
+}
+
+// TTL 读取 Key 的剩余有效期
+func (c *cacheRepo) TTL(key string) (time.Duration, error) {
+	ttl, err := c.client.TTL(context.Background(), key).Result()
+	if err != nil {
+		return -1, errors.Wrapf(err, "redis get key: %s err", key)
+	}
+	return ttl, nil
+}
+
+// Expire 设置 Key 的有效期
+func (c *cacheRepo) Expire(key string, ttl time.Duration) bool {
+	ok, _ := c.client.Expire(context.Background(), key, ttl).Result()
+	return ok
+}
+
+// ExpireAt 设置 Key 的过期时间
+func (c *cacheRepo) ExpireAt(key string, ttl time.Time) bool {
+	ok, _ := c.client.ExpireAt(context.Background(), key, ttl).Result()
+	return ok
+}
+
+// Del 删除 Key，Key 不存在时返回 false
+func (c *cacheRepo) Del(key string, options ...Option) bool {
+	opt, ts := newOption(options...)
+	defer opt.record(ts, "del", key, "", 0)
+
+	if key == "" {
+		return true
+	}
+	value, _ := c.client.Del(opt.Ctx, key).Result()
+	return value > 0
+}
+
+// Exists 所有 Key 都存在时返回 true
+func (c *cacheRepo) Exists(keys ...string) bool {
+	if len(keys) == 0 {
+		return true
+	}
+	value, _ := c.client.Exists(context.Background(), keys...).Result()
+	return value == int64(len(keys))
+}
+
+// Incr 自增 Key 并返回自增后的值
+func (c *cacheRepo) Incr(key string, options ...Option) int64 {
+	opt, ts := newOption(options...)
+	defer opt.record(ts, "incr", key, "", 0)
+
+	value, _ := c.client.Incr(opt.Ctx, key).Result()
+	return value
+}
+
+// Script Lua 脚本，脚本内的多个操作原子执行
+type Script struct {
+	script *redis.Script
+}
+
+// DelIfEqualScript 仅当 KEYS[1] 的值为 ARGV[1] 时删除，返回删除的个数；
+// 用于释放以 SetNX 设置持有者的锁，避免删除过期后被其他持有者获得的锁
+var DelIfEqualScript = NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// NewScript 创建 Lua 脚本，应作为包级变量复用，执行时优先以 EVALSHA 发送
+func NewScript(src string) *Script {
+	return &Script{script: redis.NewScript(src)}
+}
+
+// Eval 执行 Lua 脚本，脚本返回 nil 时返回 nil 及 nil 错误
+func (c *cacheRepo) Eval(script *Script, keys []string, args ...interface{}) (interface{}, error) {
+	value, err := script.script.Run(context.Background(), c.client, keys, args...).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "redis eval script on keys: %v err", keys)
+	}
+	return value, nil
+}
+
+// Close 关闭连接
+func (c *cacheRepo) Close() error {
+	return c.client.Close()
+}
+
+// Version 读取 Redis 服务端版本
+func (c *cacheRepo) Version() string {
+	server := c.client.Info(context.Background(), "server").Val()
+	for _, line := range strings.Split(server, "\r\n") {
+		if strings.HasPrefix(line, "redis_version:") {
+			return strings.TrimPrefix(line, "redis_version:")
+		}
+	}
+	return ""
+}
+
+// PoolStats 连接池统计信息
+func (c *cacheRepo) PoolStats() *PoolStats {
+	stats := c.client.PoolStats()
+	return &PoolStats{
+		Hits:       stats.Hits,
+		Misses:     stats.Misses,
+		Timeouts:   stats.Timeouts,
+		TotalConns: stats.TotalConns,
+		IdleConns:  stats.IdleConns,
+		StaleConns: stats.StaleConns,
+	}
+}
+
+func newOption(options ...Option) (*option, time.Time) {
+	opt := &option{Ctx: context.Background()}
+	for _, f := range options {
+		f(opt)
+	}
+	return opt, time.Now()
+}
+
+// record 开启 WithTrace 时将本次操作追加至 Trace
+func (opt *option) record(ts time.Time, handle, key, value string, ttl time.Duration) {
+	if opt.Trace == nil {
+		return
+	}
+
+	opt.Redis.Timestamp = ts.Format(timeutil.CSTLayout)
+	opt.Redis.Handle = handle
+	opt.Redis.Key = key
+	opt.Redis.Value = value
+	opt.Redis.TTL = ttl.Minutes()
+	opt.Redis.CostSeconds = time.Since(ts).Seconds()
+	opt.Trace.AppendRedis(opt.Redis)
 }
@@ -0,0 +1,152 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/kisun-bit/aio_dashboard/internal/depends/redis"
+	"github.com/kisun-bit/aio_dashboard/internal/proposal"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics 服务指标，以 Prometheus 文本格式暴露
+type Metrics struct {
+	namespace string
+	registry  *prometheus.Registry
+
+	requests      *prometheus.CounterVec
+	latency       *prometheus.HistogramVec
+	businessCodes *prometheus.CounterVec
+	deprecated    *prometheus.CounterVec
+}
+
+// New 创建服务指标，namespace 作为所有指标名称的前缀
+func New(namespace string) *Metrics {
+	m := &Metrics{
+		namespace: namespace,
+		registry:  prometheus.NewRegistry(),
+
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "HTTP 请求总数",
+		}, []string{"path", "method", "http_code"}),
+
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "HTTP 请求耗时(单位秒)",
+			Buckets:   []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+		}, []string{"path", "method"}),
+
+		businessCodes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "business_code_total",
+			Help:      "返回的业务码总数",
+		}, []string{"path", "method", "business_code"}),
+
+		deprecated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "deprecated_requests_total",
+			Help:      "已弃用接口的调用总数，用于判断旧版本客户端是否仍在使用",
+		}, []string{"path", "method", "version"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{Namespace: namespace}),
+		m.requests,
+		m.latency,
+		m.businessCodes,
+		m.deprecated,
+	)
+
+	return m
+}
+
+// Record 记录单次请求的指标，满足 proposal.RecordHandler
+func (m *Metrics) Record(msg *proposal.MetricsMessage) {
+	if msg == nil {
+		return
+	}
+
+	m.requests.WithLabelValues(msg.Path, msg.Method, strconv.Itoa(msg.HTTPCode)).Inc()
+	m.latency.WithLabelValues(msg.Path, msg.Method).Observe(msg.CostSeconds)
+	m.businessCodes.WithLabelValues(msg.Path, msg.Method, strconv.Itoa(msg.BusinessCode)).Inc()
+	if msg.Deprecated {
+		m.deprecated.WithLabelValues(msg.Path, msg.Method, msg.Version).Inc()
+	}
+}
+
+// RegisterDB 注册数据库连接池指标，name 用于区分读库与写库
+func (m *Metrics) RegisterDB(name string, db *sql.DB) error {
+	return m.registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterCache 注册缓存连接池指标
+func (m *Metrics) RegisterCache(cache redis.Operator) error {
+	return m.registry.Register(newCachePoolCollector(m.namespace, cache))
+}
+
+// Handler 以 Prometheus 文本格式输出所有指标
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// cachePoolCollector 在采集时读取 redis.Operator 的连接池统计信息
+type cachePoolCollector struct {
+	cache redis.Operator
+
+	hits       *prometheus.Desc
+	misses     *prometheus.Desc
+	timeouts   *prometheus.Desc
+	totalConns *prometheus.Desc
+	idleConns  *prometheus.Desc
+	staleConns *prometheus.Desc
+}
+
+func newCachePoolCollector(namespace string, cache redis.Operator) *cachePoolCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "cache_pool", name), help, nil, nil)
+	}
+
+	return &cachePoolCollector{
+		cache:      cache,
+		hits:       desc("hits_total", "从连接池中获取到空闲连接的次数"),
+		misses:     desc("misses_total", "连接池中没有空闲连接的次数"),
+		timeouts:   desc("timeouts_total", "等待连接超时的次数"),
+		totalConns: desc("total_connections", "连接总数"),
+		idleConns:  desc("idle_connections", "空闲连接数"),
+		staleConns: desc("stale_connections_total", "已移除的过期连接数"),
+	}
+}
+
+func (c *cachePoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.timeouts
+	ch <- c.totalConns
+	ch <- c.idleConns
+	ch <- c.staleConns
+}
+
+func (c *cachePoolCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.cache.PoolStats()
+	if stats == nil {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(c.timeouts, prometheus.CounterValue, float64(stats.Timeouts))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(stats.TotalConns))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(stats.IdleConns))
+	ch <- prometheus.MustNewConstMetric(c.staleConns, prometheus.CounterValue, float64(stats.StaleConns))
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"errors"
+
+	"github.com/kisun-bit/aio_dashboard/internal/code"
+	"github.com/kisun-bit/aio_dashboard/internal/proposal"
+	"github.com/kisun-bit/aio_dashboard/pkg/core"
+)
+
+// CheckAgent 根据 mTLS 客户端证书获取备份代理身份，配合 core.WrapAgentHandler 使用；
+// 证书链已在 TLS 握手时由内置 CA 验证，此处仅检查吊销列表
+func (m Middleware) CheckAgent(ctx core.ContextWrap) (identity proposal.AgentIdentity, err core.BusinessError) {
+	if m.Agents == nil {
+		return identity, core.Code(code.AgentCertificateError).WithError(errors.New("agent authority not ready"))
+	}
+
+	state := ctx.Request().TLS
+	if state == nil || len(state.VerifiedChains) == 0 {
+		return identity, core.Code(code.AgentCertificateError).WithError(errors.New("verified client certificate required"))
+	}
+
+	identity, identifyErr := m.Agents.Identify(state.VerifiedChains[0][0])
+	if identifyErr != nil {
+		return identity, core.Code(code.AgentCertificateError).WithError(identifyErr)
+	}
+	return identity, nil
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/kisun-bit/aio_dashboard/configs"
+	"github.com/kisun-bit/aio_dashboard/internal/depends/redis"
+	"github.com/kisun-bit/aio_dashboard/pkg/core"
+)
+
+var _ core.IdempotencyStore = (*redisIdempotencyStore)(nil)
+
+// redisIdempotencyStore 基于 Redis 保存幂等结果，多个 dashboard 实例共享；
+// 处理中标记通过 SET NX PX 原子地设置持有者及有效期，清除时校验持有者
+type redisIdempotencyStore struct {
+	cache redis.Operator
+}
+
+// NewRedisIdempotencyStore 创建基于 Redis 的幂等结果存储
+func NewRedisIdempotencyStore(cache redis.Operator) core.IdempotencyStore {
+	return &redisIdempotencyStore{cache: cache}
+}
+
+func (s *redisIdempotencyStore) lockKey(key string) string {
+	return configs.RedisKeyPrefixIdempotency + key + ":lock"
+}
+
+func (s *redisIdempotencyStore) Acquire(key, owner string, ttl time.Duration) (bool, error) {
+	return s.cache.SetNX(s.lockKey(key), owner, ttl)
+}
+
+func (s *redisIdempotencyStore) Release(key, owner string) {
+	_, _ = s.cache.Eval(redis.DelIfEqualScript, []string{s.lockKey(key)}, owner)
+}
+
+func (s *redisIdempotencyStore) Load(key string) ([]byte, bool, error) {
+	key = configs.RedisKeyPrefixIdempotency + key
+	if !s.cache.Exists(key) {
+		return nil, false, nil
+	}
+
+	value, err := s.cache.Get(key)
+	if err != nil {
+		return nil, false, err
+	}
+	return []byte(value), true, nil
+}
+
+func (s *redisIdempotencyStore) Save(key string, value []byte, ttl time.Duration) error {
+	return s.cache.Set(configs.RedisKeyPrefixIdempotency+key, string(value), ttl)
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRedisIdempotencyStoreLock(t *testing.T) {
+	server, cache := newTestCache(t)
+	store := NewRedisIdempotencyStore(cache)
+
+	if ok, err := store.Acquire("k", "owner-a", time.Minute); err != nil || !ok {
+		t.Fatalf("first acquire = %v, %v", ok, err)
+	}
+	if ok, _ := store.Acquire("k", "owner-b", time.Minute); ok {
+		t.Fatal("second acquire should fail while locked")
+	}
+
+	store.Release("k", "owner-b")
+	if ok, _ := store.Acquire("k", "owner-b", time.Minute); ok {
+		t.Fatal("release by another owner should keep the lock")
+	}
+
+	store.Release("k", "owner-a")
+	if ok, _ := store.Acquire("k", "owner-b", time.Minute); !ok {
+		t.Fatal("acquire should succeed after owner released")
+	}
+
+	// 标记过期后被其他请求获得，原持有者的清除不影响新的持有者
+	server.FastForward(time.Minute + time.Second)
+	if ok, _ := store.Acquire("k", "owner-c", time.Minute); !ok {
+		t.Fatal("acquire should succeed after lock expired")
+	}
+	store.Release("k", "owner-b")
+	if ok, _ := store.Acquire("k", "owner-d", time.Minute); ok {
+		t.Fatal("stale owner must not release the new lock")
+	}
+}
+
+func TestRedisIdempotencyStoreRecord(t *testing.T) {
+	server, cache := newTestCache(t)
+	store := NewRedisIdempotencyStore(cache)
+
+	if _, ok, err := store.Load("k"); ok || err != nil {
+		t.Fatalf("load missing = %v, %v", ok, err)
+	}
+	if err := store.Save("k", []byte(`{"http_code":200}`), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if value, ok, err := store.Load("k"); !ok || err != nil || string(value) != `{"http_code":200}` {
+		t.Fatalf("load = %q, %v, %v", value, ok, err)
+	}
+
+	server.FastForward(time.Hour + time.Second)
+	if _, ok, _ := store.Load("k"); ok {
+		t.Error("record should expire")
+	}
+}
@@ -1,4 +1,54 @@
 package middleware
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/kisun-bit/aio_dashboard/configs"
+	"github.com/kisun-bit/aio_dashboard/internal/agent"
+	"github.com/kisun-bit/aio_dashboard/internal/code"
+	"github.com/kisun-bit/aio_dashboard/internal/depends/redis"
+	"github.com/kisun-bit/aio_dashboard/internal/proposal"
+	"github.com/kisun-bit/aio_dashboard/pkg/core"
+)
+
 type Middleware struct {
+	Cache  redis.Operator
+	Agents *agent.Authority // 备份代理的证书管理，未启用 mTLS 时为 nil
+}
+
+// CheckLogin 根据 Header 中的登录 Token 获取当前用户信息，配合 core.WrapAuthHandler 使用
+func (m Middleware) CheckLogin(ctx core.ContextWrap) (info proposal.SessionUserInfo, err core.BusinessError) {
+	token := ctx.GetHeader(configs.HeaderLoginToken)
+	if token == "" {
+		return info, core.Code(code.AuthorizationError).WithError(errors.New("header token required"))
+	}
+	if m.Cache == nil {
+		return info, core.Code(code.AuthorizationError).WithError(errors.New("cache not ready"))
+	}
+
+	raw, cacheErr := m.Cache.Get(configs.RedisKeyPrefixLoginUser+token,
+		redis.WithContext(ctx.RequestContext()),
+		redis.WithTrace(ctx.Trace()),
+	)
+	if cacheErr != nil {
+		return info, core.Code(code.AuthorizationError).WithError(cacheErr)
+	}
+
+	if jsonErr := json.Unmarshal([]byte(raw), &info); jsonErr != nil {
+		return info, core.Code(code.AuthorizationError).WithError(jsonErr)
+	}
+	return info, nil
+}
+
+// CheckAdmin 在 CheckLogin 的基础上要求当前用户为管理员，配合 core.WrapAuthHandler 使用
+func (m Middleware) CheckAdmin(ctx core.ContextWrap) (info proposal.SessionUserInfo, err core.BusinessError) {
+	if info, err = m.CheckLogin(ctx); err != nil {
+		return info, err
+	}
+	if !info.IsAdmin {
+		return info, core.Code(code.PermissionDenied).WithError(fmt.Errorf("user %s is not an admin", info.UserName))
+	}
+	return info, nil
 }
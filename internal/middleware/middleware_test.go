@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kisun-bit/aio_dashboard/configs"
+	"github.com/kisun-bit/aio_dashboard/pkg/core"
+	"go.uber.org/zap"
+)
+
+func TestCheckAdmin(t *testing.T) {
+	_, cache := newTestCache(t)
+	_ = cache.Set(configs.RedisKeyPrefixLoginUser+"admin-token", `{"user_id":1,"user_name":"root","is_admin":true}`, time.Minute)
+	_ = cache.Set(configs.RedisKeyPrefixLoginUser+"user-token", `{"user_id":2,"user_name":"guest"}`, time.Minute)
+
+	mux, err := core.New(zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.Group("/api", core.WrapAuthHandler(Middleware{Cache: cache}.CheckAdmin)).POST("/admin", func(ctx core.ContextWrap) {
+		ctx.Payload(ctx.SessionUserInfo().UserName)
+	})
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"admin", "admin-token", http.StatusOK},
+		{"not admin", "user-token", http.StatusForbidden},
+		{"unknown token", "missing", http.StatusUnauthorized},
+		{"no token", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/admin", nil)
+			if tt.token != "" {
+				req.Header.Set(configs.HeaderLoginToken, tt.token)
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("code = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kisun-bit/aio_dashboard/configs"
+	"github.com/kisun-bit/aio_dashboard/internal/depends/redis"
+	"github.com/kisun-bit/aio_dashboard/pkg/core"
+)
+
+var _ core.RateLimiter = (*redisLimiter)(nil)
+
+// tokenBucketScript 令牌桶，令牌数及补充时刻保存于同一个 Hash，读取、补充与扣减在脚本内原子完成；
+// KEYS[1] 为桶，ARGV[1] 为 rate，ARGV[2] 为当前时刻(毫秒)；返回 {是否放行, 预计可重试的等待毫秒数}。
+// 桶在 1 秒内即可补满，空闲 2 秒后过期，过期后重建与补满等价
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local now = tonumber(ARGV[2])
+
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "last")
+local tokens = tonumber(bucket[1])
+local last = tonumber(bucket[2])
+if tokens == nil or last == nil then
+	tokens = rate
+	last = now
+end
+
+if now > last then
+	tokens = math.min(rate, tokens + (now - last) * rate / 1000)
+	last = now
+end
+
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) * 1000 / rate)
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "last", tostring(last))
+redis.call("PEXPIRE", KEYS[1], 2000)
+return {allowed, wait}
+`)
+
+// redisLimiter 基于 Redis 的令牌桶限流器，多个 dashboard 实例共享限额；
+// 补充令牌按各实例的本地时钟计算，时钟回拨时不补充，实例间的时钟应保持同步
+type redisLimiter struct {
+	cache redis.Operator
+}
+
+// NewRedisLimiter 创建基于 Redis 的限流器
+func NewRedisLimiter(cache redis.Operator) core.RateLimiter {
+	return &redisLimiter{cache: cache}
+}
+
+func (l *redisLimiter) Allow(key string, rate int) (bool, time.Duration, error) {
+	value, err := l.cache.Eval(tokenBucketScript, []string{configs.RedisKeyPrefixRateLimit + key},
+		rate, time.Now().UnixNano()/int64(time.Millisecond))
+	if err != nil {
+		return false, 0, err
+	}
+
+	result, ok := value.([]interface{})
+	if !ok || len(result) != 2 {
+		return false, 0, fmt.Errorf("unexpected token bucket result: %v", value)
+	}
+	allowed, _ := result[0].(int64)
+	wait, _ := result[1].(int64)
+
+	return allowed == 1, time.Duration(wait) * time.Millisecond, nil
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/kisun-bit/aio_dashboard/internal/depends/redis"
+)
+
+func newTestCache(t *testing.T) (*miniredis.Miniredis, redis.Operator) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	cache, err := redis.Dial(server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = cache.Close() })
+	return server, cache
+}
+
+func TestRedisLimiterBurstAndRefill(t *testing.T) {
+	_, cache := newTestCache(t)
+	limiter := NewRedisLimiter(cache)
+
+	for i := 0; i < 5; i++ {
+		if ok, _, err := limiter.Allow("ip:1.1.1.1", 5); err != nil || !ok {
+			t.Fatalf("request %d rejected: %v", i, err)
+		}
+	}
+
+	ok, retryAfter, err := limiter.Allow("ip:1.1.1.1", 5)
+	if err != nil || ok {
+		t.Fatalf("want rejected after burst, ok=%v err=%v", ok, err)
+	}
+	if retryAfter <= 0 || retryAfter > 200*time.Millisecond {
+		t.Errorf("retryAfter = %v, want (0, 200ms]", retryAfter)
+	}
+
+	if ok, _, _ = limiter.Allow("ip:2.2.2.2", 5); !ok {
+		t.Error("other key should have its own bucket")
+	}
+
+	time.Sleep(retryAfter + 10*time.Millisecond)
+	if ok, _, _ = limiter.Allow("ip:1.1.1.1", 5); !ok {
+		t.Error("want allowed after refill")
+	}
+}
+
+func TestRedisLimiterBucketExpires(t *testing.T) {
+	server, cache := newTestCache(t)
+	limiter := NewRedisLimiter(cache)
+
+	if ok, _, err := limiter.Allow("global", 1); err != nil || !ok {
+		t.Fatalf("first request rejected: %v", err)
+	}
+	keys := server.Keys()
+	if len(keys) != 1 || server.TTL(keys[0]) <= 0 {
+		t.Fatalf("bucket keys = %v, want one key with ttl", keys)
+	}
+
+	server.FastForward(3 * time.Second)
+	if server.Exists(keys[0]) {
+		t.Error("idle bucket should expire")
+	}
+}
+
+func TestRedisLimiterError(t *testing.T) {
+	server, cache := newTestCache(t)
+	server.Close()
+
+	if _, _, err := NewRedisLimiter(cache).Allow("global", 1); err == nil {
+		t.Error("want error when redis unavailable")
+	}
+}
@@ -0,0 +1,10 @@
+package proposal
+
+import "time"
+
+// AgentIdentity 通过 mTLS 客户端证书认证的备份代理身份
+type AgentIdentity struct {
+	AgentID  string    `json:"agent_id"`  // 代理ID，即客户端证书的 CommonName
+	Serial   string    `json:"serial"`    // 客户端证书序列号(十六进制)
+	NotAfter time.Time `json:"not_after"` // 客户端证书到期时间
+}
@@ -0,0 +1,20 @@
+package proposal
+
+// MetricsMessage 指标信息
+type MetricsMessage struct {
+	ProjectName  string  `json:"project_name"`  // 项目名
+	Env          string  `json:"env"`           // 运行环境
+	TraceID      string  `json:"trace_id"`      // 唯一ID，用于追踪关联
+	HOST         string  `json:"host"`          // 请求 HOST
+	Path         string  `json:"path"`          // 路由别名，未设置别名时为路由模板
+	Method       string  `json:"method"`        // 请求 Method
+	HTTPCode     int     `json:"http_code"`     // HTTP 状态码
+	BusinessCode int     `json:"business_code"` // 业务码
+	CostSeconds  float64 `json:"cost_seconds"`  // 耗时，单位秒
+	IsSuccess    bool    `json:"is_success"`    // 状态，是否成功
+	Version      string  `json:"version"`       // 接口版本，未分版本时为空
+	Deprecated   bool    `json:"deprecated"`    // 是否为已弃用的接口
+}
+
+// RecordHandler 指标处理
+type RecordHandler func(msg *MetricsMessage)
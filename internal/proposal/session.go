@@ -0,0 +1,17 @@
+package proposal
+
+import "encoding/json"
+
+// SessionUserInfo 当前用户会话信息
+type SessionUserInfo struct {
+	UserID   int32  `json:"user_id"`   // 用户ID
+	UserName string `json:"user_name"` // 用户名
+	Language string `json:"language"`  // 语言偏好，zh-cn / en-us
+	IsAdmin  bool   `json:"is_admin"`  // 是否为管理员，管理备份代理证书等操作仅限管理员
+}
+
+// Marshal 序列化到JSON
+func (user *SessionUserInfo) Marshal() (jsonRaw []byte) {
+	jsonRaw, _ = json.Marshal(user)
+	return
+}
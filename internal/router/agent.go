@@ -0,0 +1,167 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/kisun-bit/aio_dashboard/internal/agent"
+	"github.com/kisun-bit/aio_dashboard/internal/code"
+	"github.com/kisun-bit/aio_dashboard/internal/middleware"
+	"github.com/kisun-bit/aio_dashboard/internal/proposal"
+	"github.com/kisun-bit/aio_dashboard/pkg/core"
+)
+
+type enrollmentCodeRequest struct {
+	AgentID string `json:"agent_id" binding:"omitempty,max=64"` // 代理ID，为空时随机生成
+}
+
+type enrollRequest struct {
+	Code string `json:"code" binding:"required"` // 一次性注册码
+	CSR  string `json:"csr" binding:"required"`  // PEM 格式的证书签名请求
+}
+
+type renewRequest struct {
+	CSR string `json:"csr" binding:"required"` // PEM 格式的证书签名请求
+}
+
+type revokeRequest struct {
+	Serial string `json:"serial" binding:"required"` // 证书序列号(十六进制)
+}
+
+// SetAgentRouter 注册备份代理的证书接口：
+// 管理员通过 /api/agent 创建注册码及吊销证书(仅限管理员，注册码可换取受信任的客户端证书)；
+// 代理使用注册码在 /agent/enroll 获取客户端证书，之后通过 mTLS 端口访问 /agent 下需要代理身份的接口(如续期)
+func SetAgentRouter(mux core.HTTPMixin, authority *agent.Authority, middle middleware.Middleware) {
+	admin := mux.Group("/api/agent", core.WrapAuthHandler(middle.CheckAdmin))
+
+	admin.With(core.WithDoc(core.Doc{
+		Summary:  "创建备份代理注册码",
+		Tags:     []string{"agent"},
+		Auth:     true,
+		JSON:     enrollmentCodeRequest{},
+		Response: agent.EnrollmentCode{},
+	})).POST("/enrollment-codes", func(ctx core.ContextWrap) {
+		req := new(enrollmentCodeRequest)
+		if err := ctx.ShouldBindJSON(req); err != nil {
+			ctx.AbortWithError(core.ParamBindError(err))
+			return
+		}
+
+		enrollment, err := authority.CreateEnrollmentCode(req.AgentID, ctx.SessionUserInfo().UserName)
+		if err != nil {
+			ctx.AbortWithError(core.Code(code.ServerError).WithError(err))
+			return
+		}
+		ctx.Payload(enrollment)
+	})
+
+	admin.With(core.WithDoc(core.Doc{
+		Summary:  "吊销备份代理证书",
+		Tags:     []string{"agent"},
+		Auth:     true,
+		JSON:     revokeRequest{},
+		Response: agent.IssuedCertificate{},
+	})).POST("/revoke", func(ctx core.ContextWrap) {
+		req := new(revokeRequest)
+		if err := ctx.ShouldBindJSON(req); err != nil {
+			ctx.AbortWithError(core.ParamBindError(err))
+			return
+		}
+
+		issued, err := authority.Revoke(req.Serial)
+		if errors.Is(err, agent.ErrInvalidSerial) {
+			ctx.AbortWithError(core.ParamBindError(err))
+			return
+		}
+		if errors.Is(err, agent.ErrUnknownCertificate) {
+			ctx.AbortWithError(core.Code(code.CertificateNotFound).WithError(err))
+			return
+		}
+		if err != nil {
+			ctx.AbortWithError(core.Code(code.ServerError).WithError(err))
+			return
+		}
+		ctx.Payload(issued)
+	})
+
+	agents := mux.Group("/agent")
+
+	agents.With(core.WithDoc(core.Doc{
+		Summary:  "备份代理注册，使用一次性注册码获取客户端证书",
+		Tags:     []string{"agent"},
+		JSON:     enrollRequest{},
+		Response: agent.Certificate{},
+	})).POST("/enroll", func(ctx core.ContextWrap) {
+		req := new(enrollRequest)
+		if err := ctx.ShouldBindJSON(req); err != nil {
+			ctx.AbortWithError(core.ParamBindError(err))
+			return
+		}
+
+		cert, err := authority.Enroll(req.Code, []byte(req.CSR))
+		if errors.Is(err, agent.ErrEnrollmentCode) {
+			ctx.AbortWithError(core.Code(code.EnrollmentCodeInvalid).WithError(err))
+			return
+		}
+		if errors.Is(err, agent.ErrInvalidCSR) {
+			ctx.AbortWithError(core.ParamBindError(err))
+			return
+		}
+		if err != nil {
+			ctx.AbortWithError(core.Code(code.ServerError).WithError(err))
+			return
+		}
+		ctx.Payload(cert)
+	})
+
+	agents.With(core.WithDoc(core.Doc{
+		Summary: "证书吊销列表(DER)",
+		Tags:    []string{"agent"},
+	})).GET("/crl", func(ctx core.ContextWrap) {
+		crl, err := authority.CRL()
+		if err != nil {
+			ctx.AbortWithError(core.Code(code.ServerError).WithError(err))
+			return
+		}
+
+		w := ctx.ResponseWriter()
+		w.Header().Set("Content-Type", "application/pkix-crl")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(crl)
+	})
+
+	// 以下接口仅可通过 mTLS 端口访问
+	verified := agents.Group("", core.WrapAgentHandler(middle.CheckAgent))
+
+	verified.With(core.WithDoc(core.Doc{
+		Summary:  "当前备份代理身份",
+		Tags:     []string{"agent"},
+		Response: proposal.AgentIdentity{},
+	})).GET("/identity", func(ctx core.ContextWrap) {
+		ctx.Payload(ctx.AgentIdentity())
+	})
+
+	verified.With(core.WithDoc(core.Doc{
+		Summary:  "备份代理证书续期，签发新证书后当前证书即被吊销",
+		Tags:     []string{"agent"},
+		JSON:     renewRequest{},
+		Response: agent.Certificate{},
+	})).POST("/renew", func(ctx core.ContextWrap) {
+		req := new(renewRequest)
+		if err := ctx.ShouldBindJSON(req); err != nil {
+			ctx.AbortWithError(core.ParamBindError(err))
+			return
+		}
+
+		cert, err := authority.Renew(ctx.AgentIdentity(), []byte(req.CSR))
+		if errors.Is(err, agent.ErrInvalidCSR) {
+			ctx.AbortWithError(core.ParamBindError(err))
+			return
+		}
+		if err != nil {
+			ctx.AbortWithError(core.Code(code.ServerError).WithError(err))
+			return
+		}
+		ctx.Payload(cert)
+	})
+}
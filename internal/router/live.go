@@ -0,0 +1,14 @@
+package router
+
+import (
+	"github.com/kisun-bit/aio_dashboard/internal/middleware"
+	"github.com/kisun-bit/aio_dashboard/pkg/core"
+)
+
+// SetLiveRouter 注册控制台实时推送的 WebSocket 入口，登录验证与 HTTP 接口一致；
+// 客户端通过 subscribe / unsubscribe 消息订阅主题，内部模块通过 hub.Publish 推送
+func SetLiveRouter(mux core.HTTPMixin, hub *core.WSHub, middle middleware.Middleware) {
+	core.MountWebSocket(mux.Group("/api"), "/live", hub, nil,
+		core.WrapAuthHandler(middle.CheckLogin),
+	)
+}
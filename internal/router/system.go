@@ -0,0 +1,20 @@
+package router
+
+import (
+	"github.com/kisun-bit/aio_dashboard/internal/code"
+	"github.com/kisun-bit/aio_dashboard/pkg/core"
+)
+
+// SetSystemRouter 注册系统信息接口
+func SetSystemRouter(mux core.HTTPMixin) {
+	system := mux.Group("/system")
+
+	// 导出业务码目录，供前端及接口文档同步错误描述
+	system.With(core.WithDoc(core.Doc{
+		Summary:  "业务码目录",
+		Tags:     []string{"system"},
+		Response: []code.Entry{},
+	})).GET("/codes", func(ctx core.ContextWrap) {
+		ctx.Payload(code.All())
+	})
+}
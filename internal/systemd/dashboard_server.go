@@ -2,16 +2,36 @@ package systemd
 
 import (
 	"errors"
+	"github.com/kisun-bit/aio_dashboard/configs"
+	"github.com/kisun-bit/aio_dashboard/internal/agent"
+	"github.com/kisun-bit/aio_dashboard/internal/alert"
+	"github.com/kisun-bit/aio_dashboard/internal/code"
+	"github.com/kisun-bit/aio_dashboard/internal/cron"
 	"github.com/kisun-bit/aio_dashboard/internal/depends"
+	"github.com/kisun-bit/aio_dashboard/internal/depends/postgresql"
+	"github.com/kisun-bit/aio_dashboard/internal/depends/redis"
+	"github.com/kisun-bit/aio_dashboard/internal/metrics"
 	"github.com/kisun-bit/aio_dashboard/internal/middleware"
+	"github.com/kisun-bit/aio_dashboard/internal/router"
 	"github.com/kisun-bit/aio_dashboard/pkg/core"
+	"github.com/kisun-bit/aio_dashboard/pkg/tlsutil"
+	"github.com/kisun-bit/aio_dashboard/pkg/trace"
+	"github.com/kisun-bit/aio_dashboard/web"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"strings"
+	"time"
 )
 
 type BackendServer struct {
-	Depend depends.Dependency
-	Middle middleware.Middleware
-	HTTP   core.HTTPMixin
+	Depend  depends.Dependency
+	Middle  middleware.Middleware
+	HTTP    core.HTTPMixin
+	Alert   *alert.Dispatcher
+	Metrics *metrics.Metrics
+	Live    *core.WSHub
+	Cron    *cron.Server     // 后台任务，日志写入 cron 日志，停止服务时保存进度
+	Agents  *agent.Authority // 备份代理的证书管理，未启用 mTLS 端口时为 nil
 }
 
 func NewBackendServer(globalLogger, cronLogger *zap.SugaredLogger) (*BackendServer, error) {
@@ -19,4 +39,218 @@ func NewBackendServer(globalLogger, cronLogger *zap.SugaredLogger) (*BackendServ
 		return nil, errors.New("logger required")
 	}
 
+	// 业务码重复登记或缺少描述时拒绝启动
+	if err := code.Check(); err != nil {
+		return nil, err
+	}
+
+	redactor, err := newRedactor()
+	if err != nil {
+		return nil, err
+	}
+
+	depend, err := newDependency()
+	if err != nil {
+		return nil, err
+	}
+
+	srv := &BackendServer{
+		Depend:  depend,
+		Alert:   newAlertDispatcher(globalLogger.Desugar()),
+		Metrics: metrics.New(configs.Settings.Base.Name),
+		Live:    core.NewWSHub(),
+		Cron:    cron.New(cronLogger.Desugar()),
+	}
+	srv.Middle = middleware.Middleware{Cache: srv.Depend.Cache}
+
+	mux, err := core.New(globalLogger.Desugar(),
+		core.WithAlertNotify(srv.Alert.Notify),
+		core.WithRecordMetrics(srv.Metrics.Record),
+		core.WithStaticFS(web.Dist()),
+		core.WithHTMLTemplates(web.Templates(), "*.html"),
+		core.WithRedactor(redactor),
+		core.WithCORS(newCORSConfig()),
+		core.WithSecurityHeaders(newSecurityHeaders()),
+		core.WithTrustedProxies(splitSetting(configs.Settings.Security.TrustedProxies, ",")...),
+		core.WithRateLimit(srv.newRateLimiter(), core.RateLimit{
+			Global:   configs.MaxRequestsPerSecond,
+			PerIP:    configs.Settings.RateLimit.PerIP,
+			PerUser:  configs.Settings.RateLimit.PerUser,
+			PerAlias: configs.Settings.RateLimit.PerAlias,
+		}),
+		core.WithIdempotency(middleware.NewRedisIdempotencyStore(srv.Depend.Cache),
+			time.Duration(configs.Settings.Base.IdempotencyTTL)*time.Second),
+		core.WithCompression(configs.Settings.Base.CompressSize),
+		core.WithMaxBodySize(int64(configs.Settings.Base.MaxBodySize)<<20),
+		core.WithOpenAPI(configs.Settings.Base.SwaggerPath, core.OpenAPIInfo{
+			Title:       configs.Settings.Base.DisplayName,
+			Version:     configs.Settings.Base.Version,
+			Description: configs.Settings.Base.Description,
+			TokenHeader: configs.HeaderLoginToken,
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	srv.HTTP = mux
+	if err = srv.registerDependMetrics(); err != nil {
+		return nil, err
+	}
+	router.SetSystemRouter(mux)
+	router.SetLiveRouter(mux, srv.Live, srv.Middle)
+
+	if err = srv.enableAgents(); err != nil {
+		return nil, err
+	}
+	if srv.Agents != nil {
+		router.SetAgentRouter(mux, srv.Agents, srv.Middle)
+	}
+
+	return srv, nil
+}
+
+// newDependency 连接数据库及 Redis，任一不可用时拒绝启动
+func newDependency() (depends.Dependency, error) {
+	db, err := postgresql.New()
+	if err != nil {
+		return depends.Dependency{}, err
+	}
+
+	cache, err := redis.New()
+	if err != nil {
+		_ = db.DBRClose()
+		_ = db.DBWClose()
+		return depends.Dependency{}, err
+	}
+
+	return depends.Dependency{DB: db, Cache: cache}, nil
+}
+
+// enableAgents 配置了 mTLS 端口时加载(或生成)内置 CA，注册码及吊销列表保存于 Redis，多个实例间共享
+func (s *BackendServer) enableAgents() error {
+	settings := configs.Settings.Agent
+	if settings.MTLSPort == "" {
+		return nil
+	}
+
+	ca, err := tlsutil.LoadOrCreateCA(settings.CACertFile, settings.CAKeyFile, configs.Settings.Base.DisplayName+" Agent CA")
+	if err != nil {
+		return err
+	}
+
+	s.Agents = agent.New(ca, s.Depend.Cache,
+		agent.WithCertValidity(time.Duration(settings.CertValidity)*24*time.Hour),
+		agent.WithCodeTTL(time.Duration(settings.EnrollmentCodeTTL)*time.Second),
+	)
+	s.Middle.Agents = s.Agents
+	return nil
+}
+
+// newRateLimiter 根据配置选择限流计数的存储
+func (s *BackendServer) newRateLimiter() core.RateLimiter {
+	if configs.Settings.RateLimit.Backend == "redis" {
+		return middleware.NewRedisLimiter(s.Depend.Cache)
+	}
+	return core.NewLocalLimiter()
+}
+
+// registerDependMetrics 注册数据库及缓存连接池指标
+func (s *BackendServer) registerDependMetrics() error {
+	for name, db := range map[string]*gorm.DB{
+		"read":  s.Depend.DB.GetDBForRead(),
+		"write": s.Depend.DB.GetDBForWrite(),
+	} {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		if err = s.Metrics.RegisterDB(name, sqlDB); err != nil {
+			return err
+		}
+	}
+	return s.Metrics.RegisterCache(s.Depend.Cache)
+}
+
+// newRedactor 根据配置创建 Trace 脱敏规则，Redis Key 前缀自动加上服务名
+func newRedactor() (*trace.Redactor, error) {
+	settings := configs.Settings.Trace
+
+	var redisPrefixes []string
+	for _, prefix := range splitSetting(settings.RedactRedisPrefixes, ",") {
+		redisPrefixes = append(redisPrefixes, configs.Settings.Base.Name+":"+prefix)
+	}
+
+	return trace.NewRedactor(
+		trace.RedactHeaders(splitSetting(settings.RedactHeaders, ",")...),
+		trace.RedactJSONFields(splitSetting(settings.RedactJSONFields, ",")...),
+		trace.RedactPatterns(strings.Fields(settings.RedactPatterns)...),
+		trace.RedactRedisPrefixes(redisPrefixes...),
+	)
+}
+
+// newCORSConfig 根据配置生成跨域规则，未配置的项由 core 按运行环境补全
+func newCORSConfig() core.CORSConfig {
+	settings := configs.Settings.Security
+
+	return core.CORSConfig{
+		AllowOrigins:     splitSetting(settings.CORSAllowOrigins, ","),
+		AllowMethods:     splitSetting(settings.CORSAllowMethods, ","),
+		AllowHeaders:     splitSetting(settings.CORSAllowHeaders, ","),
+		ExposeHeaders:    splitSetting(settings.CORSExposeHeaders, ","),
+		AllowCredentials: settings.CORSAllowCredentials,
+		MaxAge:           time.Duration(settings.CORSMaxAge) * time.Second,
+	}
+}
+
+// newSecurityHeaders 根据配置生成安全 Header
+func newSecurityHeaders() core.SecurityHeaders {
+	settings := configs.Settings.Security
+
+	return core.SecurityHeaders{
+		ContentSecurityPolicy: settings.ContentSecurityPolicy,
+		HSTSMaxAge:            time.Duration(settings.HSTSMaxAge) * time.Second,
+		FrameOptions:          settings.FrameOptions,
+		ReferrerPolicy:        settings.ReferrerPolicy,
+	}
+}
+
+// splitSetting 拆分以 sep 分隔的配置项，忽略空值
+func splitSetting(value, sep string) []string {
+	var items []string
+	for _, item := range strings.Split(value, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// newAlertDispatcher 根据配置启用告警渠道
+func newAlertDispatcher(logger *zap.Logger) *alert.Dispatcher {
+	settings := configs.Settings.Alert
+
+	var notifiers []alert.Notifier
+	if settings.SMTPHost != "" && settings.MailTo != "" {
+		notifiers = append(notifiers, alert.NewEmailNotifier(
+			settings.SMTPHost,
+			settings.SMTPPort,
+			settings.SMTPUser,
+			settings.SMTPPassword,
+			settings.MailFrom,
+			strings.Split(settings.MailTo, ","),
+		))
+	}
+	if settings.WebhookURL != "" {
+		notifiers = append(notifiers, alert.NewWebhookNotifier(settings.WebhookURL, nil))
+	}
+	if settings.FilePath != "" {
+		notifiers = append(notifiers, alert.NewFileNotifier(settings.FilePath))
+	}
+
+	return alert.New(logger, notifiers,
+		alert.WithProjectName(configs.Settings.Base.Name),
+		alert.WithDedupeWindow(time.Duration(settings.DedupeWindow)*time.Second),
+		alert.WithMaxPerMinute(settings.MaxPerMinute),
+	)
 }
@@ -0,0 +1,52 @@
+package systemd
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/kisun-bit/aio_dashboard/configs"
+)
+
+// newMetricsServer 指标接口的管理服务，与业务端口分离，避免路由、连接池等内部信息对外暴露
+func (control *Systemctl) newMetricsServer() (*http.Server, net.Listener, error) {
+	settings := configs.Settings.Metrics
+
+	listener, err := net.Listen("tcp", settings.Listen)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", requireBearer(settings.Token, control.srv.Metrics.Handler()))
+
+	return &http.Server{Handler: mux}, listener, nil
+}
+
+func (control *Systemctl) runMetrics() {
+	control.globalLogger.Infof("metrics server listening on %s", control.metricsListener.Addr())
+
+	err := control.metricsServer.Serve(control.metricsListener)
+	if err != nil && !errors.Is(err, http.ErrServerClosed) && !errors.Is(err, net.ErrClosed) {
+		control.globalLogger.Errorf("metrics server err: %v", err)
+	}
+}
+
+// requireBearer 校验 Authorization: Bearer <token>，token 为空时不校验
+func requireBearer(token string, next http.Handler) http.Handler {
+	if token == "" {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
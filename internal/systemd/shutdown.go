@@ -0,0 +1,166 @@
+package systemd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kisun-bit/aio_dashboard/configs"
+	"go.uber.org/zap"
+)
+
+const (
+	stopAcceptTimeout = 5 * time.Second
+	// defaultDrainTimeout 未配置 shutdown_timeout 或配置不大于 0 时等待处理中请求的时限
+	defaultDrainTimeout = 30 * time.Second
+	checkpointTimeout   = 10 * time.Second
+	flushTimeout        = 5 * time.Second
+	closeTimeout        = 5 * time.Second
+)
+
+// shutdownPhase 停止服务的一个阶段，超出 timeout 时不再等待，继续执行下一阶段
+type shutdownPhase struct {
+	name    string
+	timeout time.Duration
+	run     func(ctx context.Context) error
+}
+
+// shutdownPhases 按顺序停止服务：停止接收连接 -> 等待处理中的请求 -> 后台任务保存进度 -> 发送告警并刷新日志 -> 关闭 Redis 及数据库；
+// 链路追踪随 trace-log 写入日志，由刷新日志一并落盘
+func (control *Systemctl) shutdownPhases() []shutdownPhase {
+	srv := control.srv
+
+	return []shutdownPhase{
+		{name: "stop accepting connections", timeout: stopAcceptTimeout, run: func(ctx context.Context) error {
+			control.server.SetKeepAlivesEnabled(false)
+			srv.Live.Close() // WebSocket 连接已被接管，不在 http.Server 的等待范围内
+			if control.redirect != nil {
+				_ = control.redirect.Close()
+			}
+			if control.metricsServer != nil {
+				_ = control.metricsServer.Close()
+			}
+			if control.agentListener != nil {
+				_ = control.agentListener.Close()
+			}
+			return control.listener.Close()
+		}},
+		{name: "drain http requests", timeout: drainTimeout(configs.Settings.Base.ShutdownTimeout), run: func(ctx context.Context) error {
+			servers := []*http.Server{control.server}
+			if control.agentServer != nil {
+				servers = append(servers, control.agentServer)
+			}
+			return drain(ctx, servers, control.cancelRequests)
+		}},
+		{name: "checkpoint cron jobs", timeout: checkpointTimeout, run: func(ctx context.Context) error {
+			return srv.Cron.Checkpoint(ctx)
+		}},
+		{name: "flush alerts and logs", timeout: flushTimeout, run: func(ctx context.Context) error {
+			srv.Alert.Close()
+			_ = control.cronLogger.Sync()
+			_ = control.globalLogger.Sync()
+			return nil
+		}},
+		{name: "close cache", timeout: closeTimeout, run: func(ctx context.Context) error {
+			return srv.Depend.Cache.Close()
+		}},
+		{name: "close database", timeout: closeTimeout, run: func(ctx context.Context) error {
+			rErr := srv.Depend.DB.DBRClose()
+			wErr := srv.Depend.DB.DBWClose()
+			if rErr != nil {
+				return rErr
+			}
+			return wErr
+		}},
+	}
+}
+
+// drainTimeout 将 shutdown_timeout(秒)转换为等待时限，未配置时使用默认值，避免不等待即强制关闭连接
+func drainTimeout(seconds int) time.Duration {
+	if seconds <= 0 {
+		return defaultDrainTimeout
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// drain 同时等待各服务处理中的请求完成；SSE 等长连接不会自行结束，到期后取消请求的 Context 并强制关闭
+func drain(ctx context.Context, servers []*http.Server, cancelRequests context.CancelFunc) error {
+	errs := make(chan error, len(servers))
+	for _, server := range servers {
+		go func(server *http.Server) {
+			errs <- server.Shutdown(ctx)
+		}(server)
+	}
+
+	var firstErr error
+	for range servers {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if !errors.Is(firstErr, context.DeadlineExceeded) {
+		return firstErr
+	}
+
+	cancelRequests()
+	for _, server := range servers {
+		_ = server.Close()
+	}
+	return errors.New("drain deadline exceeded, remaining connections are closed")
+}
+
+// shutdown 依次执行各阶段并记录进度，返回首个失败阶段的错误
+func (control *Systemctl) shutdown() error {
+	return runPhases(control.globalLogger, control.shutdownPhases())
+}
+
+// runPhases 依次执行各阶段，失败或超时的阶段不影响后续阶段，返回首个失败阶段的错误
+func runPhases(logger *zap.SugaredLogger, phases []shutdownPhase) error {
+	var firstErr error
+	for i, phase := range phases {
+		ts := time.Now()
+		logger.Infof("shutdown [%d] %s", i+1, phase.name)
+
+		err := runPhase(phase)
+		if err != nil {
+			logger.Errorf("shutdown [%d] %s failed after %v: %v", i+1, phase.name, time.Since(ts), err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s: %w", phase.name, err)
+			}
+			continue
+		}
+		logger.Infof("shutdown [%d] %s done in %v", i+1, phase.name, time.Since(ts))
+	}
+	return firstErr
+}
+
+// runPhase 执行阶段，超时后返回 context.DeadlineExceeded，阶段本身在后台继续执行
+func runPhase(phase shutdownPhase) error {
+	ctx, cancel := context.WithTimeout(context.Background(), phase.timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- fmt.Errorf("panic: %v", p)
+			}
+		}()
+		done <- phase.run(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		// 阶段自身已处理超时(如 http.Server.Shutdown)时优先返回其结果
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(time.Second):
+			return ctx.Err()
+		}
+	}
+}
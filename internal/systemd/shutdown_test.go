@@ -0,0 +1,186 @@
+package systemd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestShutdownPhaseOrder(t *testing.T) {
+	var names []string
+	for _, phase := range (&Systemctl{}).shutdownPhases() {
+		names = append(names, phase.name)
+		if phase.timeout <= 0 {
+			t.Errorf("phase %s has no timeout", phase.name)
+		}
+	}
+
+	want := []string{
+		"stop accepting connections",
+		"drain http requests",
+		"checkpoint cron jobs",
+		"flush alerts and logs",
+		"close cache",
+		"close database",
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("phases = %v, want %v", names, want)
+	}
+}
+
+func TestDrainTimeout(t *testing.T) {
+	tests := []struct {
+		seconds int
+		want    time.Duration
+	}{
+		{0, defaultDrainTimeout},
+		{-1, defaultDrainTimeout},
+		{1, time.Second},
+		{45, 45 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := drainTimeout(tt.seconds); got != tt.want {
+			t.Errorf("drainTimeout(%d) = %v, want %v", tt.seconds, got, tt.want)
+		}
+	}
+}
+
+func TestRunPhases(t *testing.T) {
+	var ran []string
+	phase := func(name string, timeout time.Duration, run func(ctx context.Context) error) shutdownPhase {
+		return shutdownPhase{name: name, timeout: timeout, run: func(ctx context.Context) error {
+			ran = append(ran, name)
+			return run(ctx)
+		}}
+	}
+
+	errFailed := errors.New("failed")
+	ts := time.Now()
+	err := runPhases(zap.NewNop().Sugar(), []shutdownPhase{
+		phase("ok", time.Second, func(context.Context) error { return nil }),
+		phase("fail", time.Second, func(context.Context) error { return errFailed }),
+		phase("honor deadline", 20*time.Millisecond, func(ctx context.Context) error {
+			<-ctx.Done()
+			return errors.New("stopped by deadline")
+		}),
+		phase("panic", time.Second, func(context.Context) error { panic("boom") }),
+		phase("last", time.Second, func(context.Context) error { return nil }),
+	})
+
+	if !errors.Is(err, errFailed) || !strings.HasPrefix(err.Error(), "fail: ") {
+		t.Errorf("runPhases() = %v, want the first failure", err)
+	}
+	if want := []string{"ok", "fail", "honor deadline", "panic", "last"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("ran %v, want %v", ran, want)
+	}
+	if cost := time.Since(ts); cost > time.Second {
+		t.Errorf("phases took %v, the deadline phase should return once its context expires", cost)
+	}
+}
+
+func TestRunPhaseTimeout(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	tests := []struct {
+		name    string
+		run     func(ctx context.Context) error
+		wantErr string
+	}{
+		{name: "done", run: func(context.Context) error { return nil }},
+		{name: "phase result preferred", run: func(ctx context.Context) error {
+			<-ctx.Done()
+			return errors.New("closed after deadline")
+		}, wantErr: "closed after deadline"},
+		{name: "ignores deadline", run: func(context.Context) error {
+			<-block
+			return nil
+		}, wantErr: context.DeadlineExceeded.Error()},
+		{name: "panic", run: func(context.Context) error { panic("boom") }, wantErr: "panic: boom"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := time.Now()
+			err := runPhase(shutdownPhase{name: tt.name, timeout: 20 * time.Millisecond, run: tt.run})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("runPhase() = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("runPhase() = %v, want %s", err, tt.wantErr)
+			}
+			if cost := time.Since(ts); cost > 2*time.Second {
+				t.Errorf("runPhase() waited %v past the timeout", cost)
+			}
+		})
+	}
+}
+
+func TestDrain(t *testing.T) {
+	requestCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+
+	started := make(chan struct{}, 1)
+	finished := make(chan struct{})
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/stream":
+			// 模拟 SSE：不会自行结束，直至请求的 Context 被取消
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			started <- struct{}{}
+			<-req.Context().Done()
+			close(finished)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	srv.Config.BaseContext = func(_ net.Listener) context.Context { return requestCtx }
+	srv.Start()
+	defer srv.Close()
+
+	t.Run("idle", func(t *testing.T) {
+		idle := httptest.NewServer(http.NotFoundHandler())
+		defer idle.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := drain(ctx, []*http.Server{idle.Config}, func() { t.Error("requests cancelled without a deadline") }); err != nil {
+			t.Fatalf("drain() = %v", err)
+		}
+	})
+
+	t.Run("forced close", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + "/stream")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		<-started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		err = drain(ctx, []*http.Server{srv.Config}, cancelRequests)
+		if err == nil || !strings.Contains(err.Error(), "drain deadline exceeded") {
+			t.Fatalf("drain() = %v, want deadline exceeded", err)
+		}
+
+		select {
+		case <-finished:
+		case <-time.After(2 * time.Second):
+			t.Fatal("long-lived request was not cancelled")
+		}
+	})
+}
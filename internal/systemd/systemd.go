@@ -1,11 +1,17 @@
 package systemd
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/kardianos/service"
 	"github.com/kisun-bit/aio_dashboard/configs"
 	"go.uber.org/zap"
+	"net"
+	"net/http"
+	"os"
 	"strings"
-	"time"
+	"syscall"
 )
 
 type SrvCtlInstruction string
@@ -21,6 +27,16 @@ type Systemctl struct {
 	globalLogger,
 	cronLogger *zap.SugaredLogger
 	srv *BackendServer
+
+	server          *http.Server
+	redirect        *http.Server // http 重定向至 https，未启用时为 nil
+	listener        net.Listener
+	agentServer     *http.Server // 备份代理的 mTLS 服务，未启用时为 nil
+	agentListener   net.Listener
+	metricsServer   *http.Server // Prometheus 指标的管理服务，未启用时为 nil
+	metricsListener net.Listener
+	cancelRequests  context.CancelFunc // 取消所有请求的 Context，用于强制结束长连接
+	failed          chan error         // 主服务异常退出的错误，Stop 返回该错误
 }
 
 func NewDashboardSrv(globalLogger, cronLogger *zap.SugaredLogger) (service.Service, error) {
@@ -50,6 +66,9 @@ func ParseInstFromArgs(args ...string) SrvCtlInstruction {
 func ResponseInst(srv service.Service, inst SrvCtlInstruction) error {
 	switch inst {
 	case Install:
+		if err := prepareCertificate(); err != nil {
+			return err
+		}
 		return srv.Install()
 	case Uninstall:
 		return srv.Uninstall()
@@ -62,6 +81,8 @@ func ResponseInst(srv service.Service, inst SrvCtlInstruction) error {
 	}
 }
 
+// Start 连接依赖并监听端口后在后台处理请求，依赖不可用或监听失败时直接返回错误；
+// 安装、卸载等指令不经过 Start，无需连接依赖
 func (control *Systemctl) Start(service.Service) error {
 	if service.Interactive() {
 		control.globalLogger.Info("running in terminal")
@@ -69,15 +90,127 @@ func (control *Systemctl) Start(service.Service) error {
 		control.globalLogger.Info("running under service manager")
 	}
 
-	go control.run()
+	srv, err := NewBackendServer(control.globalLogger, control.cronLogger)
+	if err != nil {
+		return err
+	}
+	control.srv = srv
+
+	addr := net.JoinHostPort(configs.Settings.Base.SrvIP, configs.Settings.Base.SrvPort)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	baseCtx, cancel := context.WithCancel(context.Background())
+	control.listener = listener
+	control.cancelRequests = cancel
+	control.server = &http.Server{
+		Addr:        addr,
+		Handler:     control.srv.HTTP,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+		ErrorLog:    zap.NewStdLog(control.globalLogger.Desugar()),
+	}
+
+	if isHTTPS() {
+		if control.server.TLSConfig, err = newTLSConfig(control.globalLogger); err != nil {
+			_ = listener.Close()
+			return err
+		}
+
+		if port := configs.Settings.TLS.RedirectHTTPPort; port != "" {
+			control.redirect = newRedirectServer(net.JoinHostPort(configs.Settings.Base.SrvIP, port))
+			go control.runRedirect()
+		}
+	}
+
+	if control.srv.Agents != nil {
+		if control.agentServer, control.agentListener, err = control.newAgentServer(baseCtx); err != nil {
+			_ = listener.Close()
+			return err
+		}
+		go control.runAgent()
+	}
+
+	if configs.Settings.Metrics.Listen != "" {
+		if control.metricsServer, control.metricsListener, err = control.newMetricsServer(); err != nil {
+			_ = listener.Close()
+			return err
+		}
+		go control.runMetrics()
+	}
+
+	control.srv.Cron.Start()
+	control.failed = make(chan error, 1)
+	go func() {
+		if err := control.run(); err != nil {
+			control.fail(err)
+		}
+	}()
 	return nil
 }
 
-func (control *Systemctl) run() {
-	// TODO 执行服务
+// run 处理主服务的请求直至停止，停止服务以外的原因退出时返回错误
+func (control *Systemctl) run() error {
+	control.globalLogger.Infof("%s server listening on %s", configs.Settings.Base.SrvProtocol, control.listener.Addr())
+
+	// 停止服务时先关闭 listener，此时 Serve 返回的错误不是 http.ErrServerClosed
+	var err error
+	if control.server.TLSConfig != nil {
+		err = control.server.ServeTLS(control.listener, "", "")
+	} else {
+		err = control.server.Serve(control.listener)
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) && !errors.Is(err, net.ErrClosed) {
+		return fmt.Errorf("http server err: %w", err)
+	}
+	return nil
+}
+
+// fail 主服务异常退出时向自身发送 SIGTERM，由 service.Run 调用 Stop 按阶段停止服务，Stop 返回该错误使进程以失败退出
+func (control *Systemctl) fail(err error) {
+	control.globalLogger.Errorf("%v, stopping service", err)
+	control.failed <- err
+
+	if process, pErr := os.FindProcess(os.Getpid()); pErr == nil {
+		if pErr = process.Signal(syscall.SIGTERM); pErr == nil {
+			return
+		}
+	}
+	// 无法通知 service.Run 时直接停止服务并退出
+	_ = control.shutdown()
+	os.Exit(1)
+}
+
+func (control *Systemctl) runAgent() {
+	control.globalLogger.Infof("agent mtls server listening on %s", control.agentListener.Addr())
+
+	err := control.agentServer.ServeTLS(control.agentListener, "", "")
+	if err != nil && !errors.Is(err, http.ErrServerClosed) && !errors.Is(err, net.ErrClosed) {
+		control.globalLogger.Errorf("agent mtls server err: %v", err)
+	}
 }
 
-func (control *Systemctl) Stop(service.Service) (err error) {
-	time.Sleep(2 * time.Second)
-	return err
+func (control *Systemctl) runRedirect() {
+	control.globalLogger.Infof("http redirect server listening on %s", control.redirect.Addr)
+
+	if err := control.redirect.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		control.globalLogger.Errorf("http redirect server err: %v", err)
+	}
+}
+
+// Stop 按顺序停止服务，各阶段均有时限，超时或失败时记录后继续执行后续阶段；
+// 因主服务异常退出而停止时返回该异常
+func (control *Systemctl) Stop(service.Service) error {
+	if control.server == nil {
+		return nil
+	}
+
+	err := control.shutdown()
+	select {
+	case failErr := <-control.failed:
+		return failErr
+	default:
+		return err
+	}
 }
@@ -0,0 +1,125 @@
+package systemd
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/kisun-bit/aio_dashboard/configs"
+	"github.com/kisun-bit/aio_dashboard/pkg/tlsutil"
+	"go.uber.org/zap"
+)
+
+// ProtocolHTTPS 服务使用 https 协议
+const ProtocolHTTPS = "https"
+
+func isHTTPS() bool {
+	return strings.EqualFold(configs.Settings.Base.SrvProtocol, ProtocolHTTPS)
+}
+
+// prepareCertificate 安装时证书或私钥不存在则生成自签名证书，已存在时保留
+func prepareCertificate() error {
+	if !isHTTPS() {
+		return nil
+	}
+
+	settings := configs.Settings.TLS
+	_, certErr := os.Stat(settings.CertFile)
+	_, keyErr := os.Stat(settings.KeyFile)
+	if certErr == nil && keyErr == nil {
+		return nil
+	}
+
+	hosts := []string{configs.Settings.Base.SrvIP, "localhost", "127.0.0.1"}
+	if hostname, err := os.Hostname(); err == nil {
+		hosts = append(hosts, hostname)
+	}
+	return tlsutil.GenerateSelfSigned(settings.CertFile, settings.KeyFile, hosts)
+}
+
+// newTLSConfig 根据配置生成 TLS 配置，证书文件变更后自动加载
+func newTLSConfig(logger *zap.SugaredLogger) (*tls.Config, error) {
+	settings := configs.Settings.TLS
+
+	minVersion, err := tlsutil.ParseVersion(settings.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+	cipherSuites, err := tlsutil.ParseCipherSuites(splitSetting(settings.CipherSuites, ","))
+	if err != nil {
+		return nil, err
+	}
+	reloader, err := tlsutil.NewReloader(settings.CertFile, settings.KeyFile, logger.Desugar())
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion:     minVersion,
+		CipherSuites:   cipherSuites,
+		GetCertificate: reloader.GetCertificate,
+	}, nil
+}
+
+// newRedirectServer 将 http 请求永久重定向至 https 的服务端口
+func newRedirectServer(addr string) *http.Server {
+	return &http.Server{
+		Addr: addr,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			host, _, err := net.SplitHostPort(req.Host)
+			if err != nil {
+				host = strings.Trim(req.Host, "[]")
+			}
+			target := "https://" + net.JoinHostPort(host, configs.Settings.Base.SrvPort) + req.URL.RequestURI()
+			http.Redirect(w, req, target, http.StatusPermanentRedirect)
+		}),
+	}
+}
+
+// newAgentServer 备份代理的 mTLS 服务，仅提供 /agent 下的接口，代理身份由 middleware.CheckAgent 根据客户端证书确定
+func (control *Systemctl) newAgentServer(baseCtx context.Context) (*http.Server, net.Listener, error) {
+	settings := configs.Settings.Agent
+
+	minVersion, err := tlsutil.ParseVersion(configs.Settings.TLS.MinVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hosts := append([]string{configs.Settings.Base.SrvIP, "localhost"}, splitSetting(settings.MTLSHosts, ",")...)
+	if hostname, err := os.Hostname(); err == nil {
+		hosts = append(hosts, hostname)
+	}
+	tlsConfig, err := control.srv.Agents.ServerTLSConfig(hosts, minVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	addr := net.JoinHostPort(configs.Settings.Base.SrvIP, settings.MTLSPort)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &http.Server{
+		Addr:        addr,
+		Handler:     agentOnly(control.srv.HTTP),
+		TLSConfig:   tlsConfig,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+		ErrorLog:    zap.NewStdLog(control.globalLogger.Desugar()),
+	}, listener, nil
+}
+
+// agentOnly mTLS 端口仅转发 /agent 下的请求，管理接口、页面等其余路径返回 404
+func agentOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !strings.HasPrefix(path.Clean(req.URL.Path), "/agent/") {
+			http.NotFound(w, req)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,36 @@
+package systemd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAgentOnly(t *testing.T) {
+	handler := agentOnly(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/agent/identity", http.StatusNoContent},
+		{"/agent/renew", http.StatusNoContent},
+		{"/api/agent/revoke", http.StatusNotFound},
+		{"/agent/../api/agent/revoke", http.StatusNotFound},
+		{"/agents/x", http.StatusNotFound},
+		{"/metrics", http.StatusNotFound},
+		{"/", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "http://agent.local/", nil)
+		req.URL.Path = tt.path
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s code = %d, want %d", tt.path, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/kisun-bit/aio_dashboard/internal/code"
+)
+
+const _StreamBodyName = "_stream_body_"
+
+// streamBody 流式读取请求体，边读边统计大小与 SHA-256，不缓存内容
+type streamBody struct {
+	io.ReadCloser
+
+	mux  sync.Mutex
+	size int64
+	hash hash.Hash
+}
+
+func newStreamBody(body io.ReadCloser) *streamBody {
+	return &streamBody{ReadCloser: body, hash: sha256.New()}
+}
+
+func (b *streamBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.mux.Lock()
+		b.size += int64(n)
+		b.hash.Write(p[:n])
+		b.mux.Unlock()
+	}
+	return n, err
+}
+
+// streamBodyDigest 流式请求体记录至 Trace 的信息，size 与 sha256 为 handler 实际读取的部分
+type streamBodyDigest struct {
+	Streaming bool   `json:"streaming"`
+	Size      int64  `json:"size"`
+	SHA256    string `json:"sha256"`
+}
+
+func (b *streamBody) digest() *streamBodyDigest {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	return &streamBodyDigest{
+		Streaming: true,
+		Size:      b.size,
+		SHA256:    hex.EncodeToString(b.hash.Sum(nil)),
+	}
+}
+
+// readBody 按路由配置处理请求体：limit > 0 时限制请求体大小；
+// 流式模式下不缓存请求体，否则完整读取并缓存以便记录至 Trace
+func (c *GinContext) readBody(streaming bool, limit int64) BusinessError {
+	req := c.ctx.Request
+	if limit > 0 {
+		if req.ContentLength > limit {
+			return bodyTooLarge(limit)
+		}
+		req.Body = http.MaxBytesReader(c.ctx.Writer, req.Body, limit)
+	}
+
+	if streaming {
+		body := newStreamBody(req.Body)
+		req.Body = body
+		c.ctx.Set(_StreamBodyName, body)
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return ParamBindError(err)
+	}
+
+	c.ctx.Set(_BodyName, body)                         // cache body是为了trace使用
+	req.Body = ioutil.NopCloser(bytes.NewBuffer(body)) // re-construct req body
+	return nil
+}
+
+// traceBody 记录至 Trace 的请求体：流式模式记录大小与哈希，否则记录完整内容
+func (c *GinContext) traceBody() interface{} {
+	if body, ok := c.ctx.Get(_StreamBodyName); ok {
+		return body.(*streamBody).digest()
+	}
+	return string(c.RawData())
+}
+
+// bodyTooLarge 请求体超出限制，限制大小作为返回结构中的 data
+func bodyTooLarge(limit int64) BusinessError {
+	return Code(code.RequestEntityTooLarge).WithDetails(map[string]int64{"max_body_size": limit})
+}
+
+// isBodyTooLarge 判断读取请求体的错误是否因超出大小限制
+func isBodyTooLarge(err error) (int64, bool) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return maxBytesErr.Limit, true
+	}
+	return 0, false
+}
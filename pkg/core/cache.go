@@ -0,0 +1,175 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+const _ResourceVersionName = "_resource_version_"
+
+// WithETag 开启 ETag：根据 Payload 的内容生成 ETag，handler 通过 SetResourceVersion 提供资源版本时以版本生成；
+// GET / HEAD 请求的 If-None-Match 匹配时返回 304
+func WithETag() RouteOption {
+	return func(opt *routeOption) {
+		opt.etag = true
+	}
+}
+
+// WithCacheControl 设置成功返回时的 Cache-Control，如 "private, max-age=60"；
+// 开启 ETag 而未设置时为 "no-cache"，即每次使用缓存前须经服务端验证
+func WithCacheControl(value string) RouteOption {
+	return func(opt *routeOption) {
+		opt.cacheControl = value
+	}
+}
+
+// SetResourceVersion 设置资源版本(如数据的更新时间或版本号)用于生成 ETag，
+// 返回 true 表示客户端缓存仍然有效，handler 可不再查询数据直接返回，由 HTTPMixin 返回 304
+func (c *GinContext) SetResourceVersion(version string) bool {
+	etag := makeETag(version, c.Language())
+	c.ctx.Set(_ResourceVersionName, etag)
+
+	return isConditionalMethod(c.ctx.Request.Method) && etagMatch(c.ctx.GetHeader("If-None-Match"), etag)
+}
+
+func (c *GinContext) resourceETag() string {
+	if etag, ok := c.ctx.Get(_ResourceVersionName); ok {
+		return etag.(string)
+	}
+	return ""
+}
+
+// makeETag 生成弱校验 ETag；返回的描述信息随语言变化，故语言参与计算
+func makeETag(content, lang string) string {
+	sum := sha256.Sum256([]byte(lang + "\x00" + content))
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+func isConditionalMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+// etagMatch 按弱比较判断 If-None-Match 是否包含 etag
+func etagMatch(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheResponse 处理成功返回的缓存相关 Header，返回 true 表示已返回 304
+func cacheResponse(ctx *GinContext, routeOpt *routeOption, data interface{}) bool {
+	c := ctx.ctx
+
+	etag := ctx.resourceETag()
+	if etag == "" && routeOpt.etag && isConditionalMethod(c.Request.Method) {
+		raw, err := json.Marshal(data)
+		if err == nil {
+			etag = makeETag(string(raw), ctx.Language())
+		}
+	}
+
+	cacheControl := routeOpt.cacheControl
+	if cacheControl == "" && etag != "" {
+		cacheControl = "no-cache"
+	}
+	if cacheControl != "" {
+		c.Header("Cache-Control", cacheControl)
+	}
+	if etag == "" {
+		return false
+	}
+
+	c.Header("ETag", etag)
+	c.Writer.Header().Add("Vary", "Accept-Language")
+	if etagMatch(c.GetHeader("If-None-Match"), etag) && isConditionalMethod(c.Request.Method) {
+		c.Status(http.StatusNotModified)
+		c.Writer.WriteHeaderNow()
+		return true
+	}
+	return false
+}
+
+// writeJSON 输出 JSON，超过 minSize(> 0) 字节且客户端支持时以 br / gzip 压缩
+func writeJSON(c *gin.Context, httpCode int, obj interface{}, minSize int) {
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		c.JSON(httpCode, obj)
+		return
+	}
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", "application/json; charset=utf-8")
+
+	encoding := ""
+	if minSize > 0 && len(raw) >= minSize {
+		header.Add("Vary", "Accept-Encoding")
+		encoding = negotiateEncoding(c.GetHeader("Accept-Encoding"))
+	}
+	if encoding == "" {
+		c.Data(httpCode, "application/json; charset=utf-8", raw)
+		return
+	}
+
+	buf := new(bytes.Buffer)
+	var w io.WriteCloser
+	if encoding == "br" {
+		w = brotli.NewWriterLevel(buf, brotli.DefaultCompression)
+	} else {
+		w, _ = gzip.NewWriterLevel(buf, gzip.DefaultCompression)
+	}
+	if _, err = w.Write(raw); err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		c.Data(httpCode, "application/json; charset=utf-8", raw)
+		return
+	}
+
+	header.Set("Content-Encoding", encoding)
+	header.Set("Content-Length", strconv.Itoa(buf.Len()))
+	c.Data(httpCode, "application/json; charset=utf-8", buf.Bytes())
+}
+
+// negotiateEncoding 根据 Accept-Encoding 选择 br 或 gzip，权重相同时优先 br
+func negotiateEncoding(acceptEncoding string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		if name != "br" && name != "gzip" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			if v := strings.TrimSpace(param); strings.HasPrefix(v, "q=") {
+				if parsed, err := strconv.ParseFloat(v[2:], 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > bestQ || (q == bestQ && q > 0 && name == "br") {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
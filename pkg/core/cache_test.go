@@ -0,0 +1,202 @@
+package core
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"go.uber.org/zap"
+)
+
+func TestEtagMatch(t *testing.T) {
+	etag := `W/"abc"`
+
+	tests := []struct {
+		ifNoneMatch string
+		want        bool
+	}{
+		{"", false},
+		{`W/"abc"`, true},
+		{`"abc"`, true},
+		{`"other", W/"abc"`, true},
+		{`"other"`, false},
+		{"*", true},
+		{`"ab"`, false},
+	}
+
+	for _, tt := range tests {
+		if got := etagMatch(tt.ifNoneMatch, etag); got != tt.want {
+			t.Errorf("etagMatch(%q) = %v, want %v", tt.ifNoneMatch, got, tt.want)
+		}
+	}
+}
+
+func TestMakeETagDependsOnLanguage(t *testing.T) {
+	if makeETag("v1", "zh-cn") == makeETag("v1", "en-us") {
+		t.Error("etag should differ by language")
+	}
+	if makeETag("v1", "zh-cn") != makeETag("v1", "zh-cn") {
+		t.Error("etag should be stable")
+	}
+	if !strings.HasPrefix(makeETag("v1", ""), `W/"`) {
+		t.Error("etag should be weak")
+	}
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"gzip, br", "br"},
+		{"br;q=0.5, gzip", "gzip"},
+		{"br;q=0, gzip;q=0", ""},
+		{"GZIP;q=0.8, deflate", "gzip"},
+	}
+
+	for _, tt := range tests {
+		if got := negotiateEncoding(tt.accept); got != tt.want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func newCacheTestMux(t *testing.T, options ...Option) HTTPMixin {
+	t.Helper()
+
+	mux, err := New(zap.NewNop(), options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := mux.Group("/api")
+	g.With(WithETag()).GET("/payload", func(ctx ContextWrap) {
+		ctx.Payload(map[string]string{"name": "dashboard"})
+	})
+	g.With(WithETag()).POST("/payload", func(ctx ContextWrap) {
+		ctx.Payload(map[string]string{"name": "dashboard"})
+	})
+	g.With(WithCacheControl("private, max-age=60")).GET("/versioned", func(ctx ContextWrap) {
+		if ctx.SetResourceVersion("v1") {
+			return
+		}
+		ctx.Payload(map[string]string{"version": "v1"})
+	})
+	g.GET("/large", func(ctx ContextWrap) {
+		ctx.Payload(strings.Repeat("dashboard ", 200))
+	})
+	return mux
+}
+
+func serve(mux http.Handler, method, path string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	return w
+}
+
+func TestETagConditionalRequests(t *testing.T) {
+	mux := newCacheTestMux(t)
+
+	first := serve(mux, http.MethodGet, "/api/payload", nil)
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" || first.Header().Get("Cache-Control") != "no-cache" {
+		t.Fatalf("first response = %d etag=%q cache-control=%q", first.Code, etag, first.Header().Get("Cache-Control"))
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		header   http.Header
+		wantCode int
+		wantETag bool
+	}{
+		{"matched", http.MethodGet, "/api/payload", http.Header{"If-None-Match": {etag}}, http.StatusNotModified, true},
+		{"stale", http.MethodGet, "/api/payload", http.Header{"If-None-Match": {`W/"stale"`}}, http.StatusOK, true},
+		{"other language", http.MethodGet, "/api/payload", http.Header{"If-None-Match": {etag}, "Accept-Language": {"en-US"}}, http.StatusOK, true},
+		{"post ignored", http.MethodPost, "/api/payload", http.Header{"If-None-Match": {etag}}, http.StatusOK, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(mux, tt.method, tt.path, tt.header)
+			if w.Code != tt.wantCode {
+				t.Fatalf("code = %d, want %d", w.Code, tt.wantCode)
+			}
+			if (w.Header().Get("ETag") != "") != tt.wantETag {
+				t.Errorf("etag = %q", w.Header().Get("ETag"))
+			}
+			if tt.wantCode == http.StatusNotModified && w.Body.Len() != 0 {
+				t.Errorf("304 with body %q", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestResourceVersion(t *testing.T) {
+	mux := newCacheTestMux(t)
+
+	first := serve(mux, http.MethodGet, "/api/versioned", nil)
+	if first.Code != http.StatusOK || first.Header().Get("Cache-Control") != "private, max-age=60" {
+		t.Fatalf("first response = %d cache-control=%q", first.Code, first.Header().Get("Cache-Control"))
+	}
+
+	second := serve(mux, http.MethodGet, "/api/versioned", http.Header{"If-None-Match": {first.Header().Get("ETag")}})
+	if second.Code != http.StatusNotModified || second.Body.Len() != 0 {
+		t.Fatalf("second response = %d body=%q", second.Code, second.Body.String())
+	}
+}
+
+func TestCompression(t *testing.T) {
+	mux := newCacheTestMux(t, WithCompression(1024))
+
+	tests := []struct {
+		name     string
+		path     string
+		accept   string
+		encoding string
+	}{
+		{"gzip", "/api/large", "gzip", "gzip"},
+		{"br", "/api/large", "gzip, br", "br"},
+		{"not accepted", "/api/large", "", ""},
+		{"below min size", "/api/payload", "gzip", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(mux, http.MethodGet, tt.path, http.Header{"Accept-Encoding": {tt.accept}})
+			if got := w.Header().Get("Content-Encoding"); got != tt.encoding {
+				t.Fatalf("Content-Encoding = %q, want %q", got, tt.encoding)
+			}
+
+			var body []byte
+			var err error
+			switch tt.encoding {
+			case "gzip":
+				r, gzErr := gzip.NewReader(w.Body)
+				if gzErr != nil {
+					t.Fatal(gzErr)
+				}
+				body, err = ioutil.ReadAll(r)
+			case "br":
+				body, err = ioutil.ReadAll(brotli.NewReader(w.Body))
+			default:
+				body = w.Body.Bytes()
+			}
+			if err != nil || !strings.Contains(string(body), "dashboard") {
+				t.Errorf("body = %.60q, err = %v", body, err)
+			}
+		})
+	}
+}
@@ -1,17 +1,19 @@
 package core
 
 import (
-	"bytes"
 	innerctx "context"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
+	"github.com/kisun-bit/aio_dashboard/configs"
+	"github.com/kisun-bit/aio_dashboard/internal/proposal"
 	"github.com/kisun-bit/aio_dashboard/pkg/trace"
 	"go.uber.org/zap"
-	"io/ioutil"
+	"io"
 	"net/http"
 	"net/url"
 	"strings"
 	"sync"
+	"time"
 )
 
 type HandlerFunc func(c ContextWrap)
@@ -26,12 +28,14 @@ const (
 	_PayloadName      = "_payload_"
 	_GraphPayloadName = "_graph_payload_"
 	_SessionUserInfo  = "_session_user_info"
+	_AgentIdentity    = "_agent_identity"
 	_AbortErrorName   = "_abort_error_"
 	_IsRecordMetrics  = "_is_record_metrics_"
+	_StaticName       = "_static_"
 )
 
 type ContextWrap interface {
-	init()
+	init(streaming bool, maxBodySize int64) BusinessError
 
 	// ShouldBindQuery 反序列化 querystring
 	// tag: `form:"xxx"` (注：不要写成 query)
@@ -70,6 +74,10 @@ type ContextWrap interface {
 	Payload(payload interface{})
 	getPayload() interface{}
 
+	// SetResourceVersion 设置资源版本用于生成 ETag，返回 true 表示客户端缓存仍然有效，handler 可直接返回
+	SetResourceVersion(version string) bool
+	resourceETag() string
+
 	// GraphPayload GraphQL返回值 与 api 返回结构不同
 	GraphPayload(payload interface{})
 	getGraphPayload() interface{}
@@ -77,6 +85,20 @@ type ContextWrap interface {
 	// HTML 返回界面
 	HTML(name string, obj interface{})
 
+	// SSE 以 Server-Sent Events 推送数据(如任务进度)，handle 返回后结束推送；
+	// 推送期间按心跳间隔保活，客户端断开时 stream.Done() 关闭
+	SSE(handle func(stream SSEStream), options ...SSEOption)
+
+	// File 下载文件，支持 Range / If-Range 断点续传及 ETag 缓存验证，下载进度记录至 Trace；
+	// name 为下载时的文件名，为空时取 filePath 中的文件名
+	File(filePath, name string)
+
+	// ServeContent 下载 content，支持同 File
+	ServeContent(name string, modTime time.Time, content io.ReadSeeker)
+
+	// UploadChunk 接收分片上传的一个分片，返回当前上传进度
+	UploadChunk(store *UploadStore, uploadID string) (*UploadStatus, BusinessError)
+
 	// AbortWithError 错误返回
 	AbortWithError(err BusinessError)
 	abortError() BusinessError
@@ -92,6 +114,13 @@ type ContextWrap interface {
 	SessionUserInfo() proposal.SessionUserInfo
 	setSessionUserInfo(info proposal.SessionUserInfo)
 
+	// AgentIdentity 通过 mTLS 客户端证书认证的备份代理身份
+	AgentIdentity() proposal.AgentIdentity
+	setAgentIdentity(identity proposal.AgentIdentity)
+
+	// Language 当前请求的语言(configs.ZhCN / configs.EnUS)，优先使用用户偏好，其次为 Accept-Language
+	Language() string
+
 	// Alias 设置路由别名 for metrics path
 	Alias() string
 	setAlias(path string)
@@ -107,7 +136,7 @@ type ContextWrap interface {
 	RequestPostFormParams() url.Values
 	// Request 获取 Request 对象
 	Request() *http.Request
-	// RawData 获取 Request.Body
+	// RawData 获取 Request.Body，流式模式(WithStreaming)的路由为 nil
 	RawData() []byte
 	// Method 获取 Request.Method
 	Method() string
@@ -132,47 +161,43 @@ type StdContext struct {
 	innerctx.Context
 	Trace
 	*zap.Logger
+	Language string // 请求语言，用于返回本地化的业务码描述
 }
 
-func (c *GinContext) init() {
-	body, err := c.ctx.GetRawData()
-	if err != nil {
-		panic(err)
-	}
-
-	c.ctx.Set(_BodyName, body)                                   // cache body是为了trace使用
-	c.ctx.Request.Body = ioutil.NopCloser(bytes.NewBuffer(body)) // re-construct req body
+// init 读取请求体，详见 readBody
+func (c *GinContext) init(streaming bool, maxBodySize int64) BusinessError {
+	return c.readBody(streaming, maxBodySize)
 }
 
 // ShouldBindQuery 反序列化querystring
 // tag: `form:"xxx"` (注：不要写成query)
 func (c *GinContext) ShouldBindQuery(obj interface{}) error {
-	return c.ctx.ShouldBindWith(obj, binding.Query)
+	return translateBindError(c.ctx.ShouldBindWith(obj, binding.Query), c.Language())
 }
 
 // ShouldBindPostForm 反序列化 postform (querystring 会被忽略)
 // tag: `form:"xxx"`
 func (c *GinContext) ShouldBindPostForm(obj interface{}) error {
-	return c.ctx.ShouldBindWith(obj, binding.FormPost)
+	return translateBindError(c.ctx.ShouldBindWith(obj, binding.FormPost), c.Language())
 }
 
 // ShouldBindForm 同时反序列化querystring和postform;
 // 当querystring和postform存在相同字段时，postform优先使用。
 // tag: `form:"xxx"`
 func (c *GinContext) ShouldBindForm(obj interface{}) error {
-	return c.ctx.ShouldBindWith(obj, binding.Form)
+	return translateBindError(c.ctx.ShouldBindWith(obj, binding.Form), c.Language())
 }
 
 // ShouldBindJSON 反序列化postjson
 // tag: `json:"xxx"`
 func (c *GinContext) ShouldBindJSON(obj interface{}) error {
-	return c.ctx.ShouldBindWith(obj, binding.JSON)
+	return translateBindError(c.ctx.ShouldBindWith(obj, binding.JSON), c.Language())
 }
 
 // ShouldBindURI 反序列化path参数(如路由路径为 /user/:name)
 // tag: `uri:"xxx"`
 func (c *GinContext) ShouldBindURI(obj interface{}) error {
-	return c.ctx.ShouldBindUri(obj)
+	return translateBindError(c.ctx.ShouldBindUri(obj), c.Language())
 }
 
 // Redirect 重定向
@@ -270,6 +295,29 @@ func (c *GinContext) setSessionUserInfo(info proposal.SessionUserInfo) {
 	c.ctx.Set(_SessionUserInfo, info)
 }
 
+func (c *GinContext) AgentIdentity() proposal.AgentIdentity {
+	val, ok := c.ctx.Get(_AgentIdentity)
+	if !ok {
+		return proposal.AgentIdentity{}
+	}
+
+	return val.(proposal.AgentIdentity)
+}
+
+func (c *GinContext) setAgentIdentity(identity proposal.AgentIdentity) {
+	c.ctx.Set(_AgentIdentity, identity)
+}
+
+func (c *GinContext) Language() string {
+	if lang := normalizeLanguage(c.SessionUserInfo().Language); lang != "" {
+		return lang
+	}
+	if lang := parseAcceptLanguage(c.ctx.GetHeader("Accept-Language")); lang != "" {
+		return lang
+	}
+	return configs.ZhCN
+}
+
 func (c *GinContext) AbortWithError(err BusinessError) {
 	if err != nil {
 		httpCode := err.HTTPCode()
@@ -277,13 +325,20 @@ func (c *GinContext) AbortWithError(err BusinessError) {
 			httpCode = http.StatusInternalServerError
 		}
 
-		c.ctx.AbortWithStatus(httpCode)
+		err = err.withLanguage(c.Language())
+
+		c.ctx.Abort()
+		c.ctx.Status(httpCode) // 仅记录状态码，响应体由 HTTPMixin 统一渲染
 		c.ctx.Set(_AbortErrorName, err)
 	}
 }
 
 func (c *GinContext) abortError() BusinessError {
-	err, _ := c.ctx.Get(_AbortErrorName)
+	err, ok := c.ctx.Get(_AbortErrorName)
+	if !ok || err == nil {
+		return nil
+	}
+
 	return err.(BusinessError)
 }
 
@@ -366,13 +421,14 @@ func (c *GinContext) URI() string {
 	return uri
 }
 
-// RequestContext (包装 Trace + Logger) 获取请求的 context (当client关闭后，会自动canceled)
+// RequestContext (包装 Trace + Logger) 获取请求的 context (当client关闭或超出路由时限后，会自动canceled)
+// 可直接传递给 gorm.DB.WithContext 及 redis.WithContext，使数据库与缓存操作随请求一同取消
 func (c *GinContext) RequestContext() StdContext {
 	return StdContext{
-		//c.ctx.Request.Context(),
-		innerctx.Background(),
+		c.ctx.Request.Context(),
 		c.Trace(),
 		c.Logger(),
+		c.Language(),
 	}
 }
 
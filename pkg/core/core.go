@@ -0,0 +1,470 @@
+package core
+
+import (
+	innerctx "context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kisun-bit/aio_dashboard/configs"
+	"github.com/kisun-bit/aio_dashboard/internal/code"
+	"github.com/kisun-bit/aio_dashboard/internal/proposal"
+	"github.com/kisun-bit/aio_dashboard/pkg/env"
+	"github.com/kisun-bit/aio_dashboard/pkg/trace"
+	"go.uber.org/zap"
+)
+
+// Option 自定义 HTTPMixin 配置
+type Option func(*option)
+
+type option struct {
+	alertNotify   proposal.NotifyHandler
+	recordHandler proposal.RecordHandler
+	staticFS      fs.FS
+	htmlFS        fs.FS
+	htmlPatterns  []string
+	openAPIPath   string
+	openAPIInfo   OpenAPIInfo
+	maxBodySize   int64
+	redactor      *trace.Redactor
+	compressSize  int
+
+	cors            *CORSConfig
+	trustedProxies  []string
+	securityHeaders SecurityHeaders
+	rateLimiter     *rateLimiter
+	idempotency     *idempotency
+}
+
+// WithAlertNotify 设置告警通知
+func WithAlertNotify(notifyHandler proposal.NotifyHandler) Option {
+	return func(opt *option) {
+		opt.alertNotify = notifyHandler
+	}
+}
+
+// WithRecordMetrics 设置记录接口指标，路由可通过 DisableRecordMetrics 跳过记录
+func WithRecordMetrics(recordHandler proposal.RecordHandler) Option {
+	return func(opt *option) {
+		opt.recordHandler = recordHandler
+	}
+}
+
+// WithStaticFS 挂载前端 SPA 静态资源：未注册路由的 GET 请求优先返回静态文件，
+// 页面请求回退至 index.html 以支持 history 路由
+func WithStaticFS(fsys fs.FS) Option {
+	return func(opt *option) {
+		opt.staticFS = fsys
+	}
+}
+
+// WithHTMLTemplates 加载服务端模板，供 ContextWrap.HTML 渲染
+func WithHTMLTemplates(fsys fs.FS, patterns ...string) Option {
+	return func(opt *option) {
+		opt.htmlFS = fsys
+		opt.htmlPatterns = patterns
+	}
+}
+
+// AliasForRecordMetrics 对请求路径起个别名，用于记录指标及按别名限流
+func AliasForRecordMetrics(path string) HandlerFunc {
+	return func(ctx ContextWrap) {
+		ctx.setAlias(path)
+
+		if limiter := rateLimiterOf(ctx); limiter != nil && ctx.Alias() != "" {
+			limiter.allow(ctx, "alias:"+ctx.Alias(), limiter.limit.PerAlias)
+		}
+	}
+}
+
+// DisableTraceLog 禁止记录 Trace 及请求日志
+func DisableTraceLog(ctx ContextWrap) {
+	ctx.disableTrace()
+}
+
+// DisableRecordMetrics 禁止记录指标
+func DisableRecordMetrics(ctx ContextWrap) {
+	ctx.disableRecordMetrics()
+}
+
+// WrapH 将 http.Handler 包装为 HandlerFunc
+func WrapH(h http.Handler) HandlerFunc {
+	return func(ctx ContextWrap) {
+		h.ServeHTTP(ctx.ResponseWriter(), ctx.Request())
+	}
+}
+
+// WithMaxBodySize 设置全局请求体大小上限(单位字节)，超出时返回 RequestEntityTooLarge；<= 0 时不限制，
+// 路由可通过 WithBodyLimit 覆盖
+func WithMaxBodySize(size int64) Option {
+	return func(opt *option) {
+		opt.maxBodySize = size
+	}
+}
+
+// WithCompression 开启响应压缩：统一返回结构的 JSON 不小于 minSize 字节时，按 Accept-Encoding 以 br 或 gzip 压缩
+func WithCompression(minSize int) Option {
+	return func(opt *option) {
+		opt.compressSize = minSize
+	}
+}
+
+// WithRedactor 设置记录 Trace、请求日志及告警前使用的脱敏规则，
+// 未设置时仅脱敏登录 Token、签名、Cookie 及 password 字段
+func WithRedactor(redactor *trace.Redactor) Option {
+	return func(opt *option) {
+		opt.redactor = redactor
+	}
+}
+
+// WrapAuthHandler 登录验证，验证通过后设置当前用户信息并按用户限流，失败时终止请求
+func WrapAuthHandler(handler func(ContextWrap) (proposal.SessionUserInfo, BusinessError)) HandlerFunc {
+	return func(ctx ContextWrap) {
+		info, err := handler(ctx)
+		if err != nil {
+			ctx.AbortWithError(err)
+			return
+		}
+		ctx.setSessionUserInfo(info)
+
+		if limiter := rateLimiterOf(ctx); limiter != nil {
+			limiter.allow(ctx, "user:"+strconv.Itoa(int(info.UserID)), limiter.limit.PerUser)
+		}
+	}
+}
+
+// WrapAgentHandler 备份代理验证，验证通过后设置代理身份，失败时终止请求
+func WrapAgentHandler(handler func(ContextWrap) (proposal.AgentIdentity, BusinessError)) HandlerFunc {
+	return func(ctx ContextWrap) {
+		identity, err := handler(ctx)
+		if err != nil {
+			ctx.AbortWithError(err)
+			return
+		}
+		ctx.setAgentIdentity(identity)
+	}
+}
+
+// WithTrustedProxies 设置可信的反向代理(IP 或 CIDR)，仅来自这些地址的请求才按 X-Forwarded-For 等 Header 识别客户端 IP；
+// 未设置时不信任任何代理，ClientIP 始终为连接的对端地址
+func WithTrustedProxies(proxies ...string) Option {
+	return func(opt *option) {
+		opt.trustedProxies = proxies
+	}
+}
+
+// WithOpenAPI 在 path 下提供根据已注册路由生成的 OpenAPI 3 文档(path/openapi.json)及 Swagger UI，pro 环境不启用
+func WithOpenAPI(path string, info OpenAPIInfo) Option {
+	return func(opt *option) {
+		opt.openAPIPath = path
+		opt.openAPIInfo = info
+	}
+}
+
+var _ HTTPMixin = (*mux)(nil)
+
+type mux struct {
+	engine *gin.Engine
+	routes *routeTable
+}
+
+func (m *mux) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	m.engine.ServeHTTP(w, req)
+}
+
+func (m *mux) Group(relativePath string, handlers ...HandlerFunc) RouterGroup {
+	return &router{
+		group: m.engine.Group(relativePath, wrapHandlers(handlers...)...),
+		table: m.routes,
+	}
+}
+
+// New 基于 gin 构建 HTTPMixin，并挂载请求的完整生命周期：
+// 创建 Trace -> 绑定 Logger -> 初始化 ContextWrap -> 执行 handlers -> 以统一结构渲染 Payload 或 AbortWithError
+// 期间发生的 panic 会被恢复为 ServerError 并记录至 Trace，开启告警的错误会交由 WithAlertNotify 发送
+func New(logger *zap.Logger, options ...Option) (HTTPMixin, error) {
+	if logger == nil {
+		return nil, errors.New("logger required")
+	}
+
+	opt := new(option)
+	for _, f := range options {
+		f(opt)
+	}
+
+	if env.Active().IsDev() {
+		gin.SetMode(gin.DebugMode)
+	} else {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	if opt.redactor == nil {
+		opt.redactor, _ = trace.NewRedactor(
+			trace.RedactHeaders(configs.HeaderLoginToken, configs.HeaderSignToken, "Cookie", "Set-Cookie"),
+			trace.RedactJSONFields("password", "token"),
+		)
+	}
+
+	m := &mux{
+		engine: gin.New(),
+		routes: newRouteTable(),
+	}
+	if err := m.engine.SetTrustedProxies(opt.trustedProxies); err != nil {
+		return nil, err
+	}
+
+	// 未注册的路由：挂载了静态资源时先尝试静态文件及 SPA 回退，仍未处理的以统一结构返回 RouteNotFound
+	var static *staticFS
+	var noRoute []gin.HandlerFunc
+	if opt.staticFS != nil {
+		var err error
+		if static, err = newStaticFS(opt.staticFS, env.Active().IsDev()); err != nil {
+			return nil, err
+		}
+		noRoute = append(noRoute, static.handle)
+	}
+	m.engine.NoRoute(append(noRoute, routeNotFound)...)
+
+	if opt.htmlFS != nil {
+		htmlRender, err := newHTMLRender(opt.htmlFS, opt.htmlPatterns, templateFuncs(static), env.Active().IsDev())
+		if err != nil {
+			return nil, err
+		}
+		m.engine.HTMLRender = htmlRender
+	}
+
+	if opt.openAPIPath != "" && !env.Active().IsPro() {
+		m.serveOpenAPI(opt.openAPIPath, opt.openAPIInfo)
+	}
+
+	// 安全 Header 及跨域先于生命周期处理，预检请求不记录 Trace 及指标
+	var cors *corsPolicy
+	if opt.cors != nil {
+		cors = newCORSPolicy(*opt.cors)
+	}
+	m.engine.Use(func(c *gin.Context) {
+		setSecurityHeaders(c, opt.securityHeaders)
+		if cors != nil && cors.handle(c) {
+			return
+		}
+		c.Next()
+	})
+
+	m.engine.Use(func(c *gin.Context) {
+		ts := time.Now()
+
+		ctx := newContext(c)
+		defer releaseContext(ctx)
+
+		t := trace.New(c.GetHeader(trace.Header))
+		ctx.setTrace(t)
+		ctx.setLogger(logger.With(zap.String("trace_id", t.ID())))
+		ctx.SetHeader(trace.Header, t.ID())
+		ctx.ableRecordMetrics()
+
+		routeOpt := m.routes.lookup(c.Request.Method, c.FullPath())
+		if routeOpt.deprecation != nil {
+			setDeprecationHeaders(c.Writer.Header(), routeOpt.deprecation)
+		}
+		if routeOpt.timeout > 0 {
+			timeoutCtx, cancel := innerctx.WithTimeout(c.Request.Context(), routeOpt.timeout)
+			defer cancel()
+
+			c.Request = c.Request.WithContext(timeoutCtx)
+		}
+
+		var idempotent *idempotentRequest
+		defer func() {
+			if err := recover(); err != nil {
+				recoverPanic(ctx, err)
+			}
+			checkTimeout(ctx)
+
+			body := render(ctx, routeOpt, opt.compressSize)
+			if idempotent != nil {
+				opt.idempotency.finish(ctx, idempotent, body)
+			}
+			notifyAlert(ctx, opt)
+
+			if opt.recordHandler != nil && ctx.isRecordMetrics() {
+				opt.recordHandler(metricsMessage(ctx, routeOpt, ts))
+			}
+
+			if t, ok := ctx.Trace().(*trace.Trace); ok && t != nil {
+				recordTrace(ctx, t, opt.redactor, body, ts)
+				opt.redactor.Apply(t)
+
+				ctx.Logger().Info("trace-log",
+					zap.String("method", c.Request.Method),
+					zap.String("path", opt.redactor.URL(ctx.URI())),
+					zap.Int("http_code", c.Writer.Status()),
+					zap.Bool("success", t.Success),
+					zap.Float64("cost_seconds", t.CostSeconds),
+					zap.Any("trace", t),
+				)
+			}
+		}()
+
+		// 限流先于读取请求体，被拒绝的请求不再占用内存；先检查范围较小的 IP 限额，避免被拒绝的请求消耗全局令牌；
+		// 用户及别名限额在对应的 handler 中检查
+		if limiter := opt.rateLimiter; limiter != nil {
+			c.Set(_RateLimiterName, limiter)
+			if !limiter.allow(ctx, "ip:"+c.ClientIP(), limiter.limit.PerIP) || !limiter.allow(ctx, "global", limiter.limit.Global) {
+				return
+			}
+		}
+
+		maxBodySize := opt.maxBodySize
+		if routeOpt.maxBodySize != 0 {
+			maxBodySize = routeOpt.maxBodySize
+		}
+		if err := ctx.init(routeOpt.streaming, maxBodySize); err != nil {
+			ctx.AbortWithError(err)
+			return
+		}
+
+		if idempotent = opt.idempotency.begin(ctx, routeOpt); c.IsAborted() {
+			return
+		}
+
+		c.Next()
+	})
+
+	return m, nil
+}
+
+// routeNotFound 以 RouteNotFound 终止请求，静态文件已写入响应时跳过
+func routeNotFound(c *gin.Context) {
+	if c.Writer.Written() {
+		return
+	}
+
+	ctx := newContext(c)
+	defer releaseContext(ctx)
+
+	ctx.AbortWithError(Code(code.RouteNotFound).WithError(fmt.Errorf("route not found: %s %s", c.Request.Method, c.Request.URL.Path)))
+}
+
+// recoverPanic 将 panic 转换为开启告警的 ServerError，并将堆栈记录至 Trace
+func recoverPanic(ctx ContextWrap, err interface{}) {
+	stackInfo := string(debug.Stack())
+	ctx.Logger().Error("got panic", zap.String("panic", fmt.Sprintf("%+v", err)), zap.String("stack", stackInfo))
+
+	if t, ok := ctx.Trace().(*trace.Trace); ok && t != nil {
+		t.AppendDebug(&trace.Debug{Key: "panic", Value: stackInfo})
+	}
+
+	businessErr := Code(code.ServerError).WithError(fmt.Errorf("panic: %+v", err)).WithAlert()
+	ctx.AbortWithError(businessErr)
+}
+
+// checkTimeout 路由处理超出 WithTimeout 设置的时限时，以 RequestTimeout 作为返回结果
+func checkTimeout(ctx ContextWrap) {
+	c := ctx.(*GinContext).ctx
+	if c.Writer.Written() || !errors.Is(c.Request.Context().Err(), innerctx.DeadlineExceeded) {
+		return
+	}
+
+	ctx.AbortWithError(Code(code.RequestTimeout).WithError(c.Request.Context().Err()))
+}
+
+// notifyAlert 对开启了告警通知(WithAlert)的 AbortWithError 发送告警
+func notifyAlert(ctx ContextWrap, opt *option) {
+	err := ctx.abortError()
+	if err == nil || !err.IsAlert() || opt.alertNotify == nil {
+		return
+	}
+
+	var errMessage, errStack string
+	if stackErr := err.StackError(); stackErr != nil {
+		errMessage = stackErr.Error()
+		errStack = fmt.Sprintf("%+v", stackErr)
+	} else {
+		errMessage = err.Message()
+	}
+
+	opt.alertNotify(&proposal.AlertMessage{
+		Env:          env.Active().Value(),
+		TraceID:      traceID(ctx),
+		HOST:         ctx.Host(),
+		URI:          opt.redactor.URL(ctx.URI()),
+		Method:       ctx.Method(),
+		BusinessCode: err.BusinessCode(),
+		ErrorMessage: opt.redactor.Text(errMessage),
+		ErrorStack:   opt.redactor.Text(errStack),
+		Timestamp:    time.Now(),
+	})
+}
+
+// metricsMessage 生成本次请求的指标信息，路径优先使用路由别名，其次为路由模板，避免指标维度膨胀
+func metricsMessage(ctx ContextWrap, routeOpt *routeOption, ts time.Time) *proposal.MetricsMessage {
+	c := ctx.(*GinContext).ctx
+
+	path := ctx.Alias()
+	if path == "" {
+		path = c.FullPath()
+	}
+	if path == "" && c.GetBool(_StaticName) {
+		path = "<static>"
+	}
+	if path == "" {
+		path = "<unmatched>"
+	}
+
+	msg := &proposal.MetricsMessage{
+		Env:         env.Active().Value(),
+		TraceID:     traceID(ctx),
+		HOST:        ctx.Host(),
+		Path:        path,
+		Method:      ctx.Method(),
+		HTTPCode:    c.Writer.Status(),
+		CostSeconds: time.Since(ts).Seconds(),
+		IsSuccess:   !c.IsAborted() && isSuccessStatus(c.Writer.Status()),
+		Version:     routeOpt.version,
+		Deprecated:  routeOpt.deprecation != nil,
+	}
+	if err := ctx.abortError(); err != nil {
+		msg.BusinessCode = err.BusinessCode()
+	}
+
+	return msg
+}
+
+// recordTrace 将本次请求的输入输出脱敏后记录至 Trace，Trace 中不会出现未脱敏的内容
+func recordTrace(ctx ContextWrap, t *trace.Trace, redactor *trace.Redactor, body interface{}, ts time.Time) {
+	c := ctx.(*GinContext).ctx
+
+	t.WithRequest(&trace.Request{
+		TTL:        "un-limit",
+		Method:     c.Request.Method,
+		DecodedURL: redactor.URL(ctx.URI()),
+		Header:     redactor.Header(c.Request.Header),
+		Body:       redactor.Body(ctx.(*GinContext).traceBody()),
+	})
+
+	resp := &trace.Response{
+		Header:      redactor.Header(c.Writer.Header()),
+		Body:        redactor.Body(body),
+		HttpCode:    c.Writer.Status(),
+		HttpCodeMsg: http.StatusText(c.Writer.Status()),
+		CostSeconds: time.Since(ts).Seconds(),
+	}
+	if err := ctx.abortError(); err != nil {
+		resp.BusinessCode = err.BusinessCode()
+		resp.BusinessCodeMsg = err.Message()
+	}
+	t.WithResponse(resp)
+
+	t.Success = !c.IsAborted() && isSuccessStatus(c.Writer.Status())
+	t.CostSeconds = time.Since(ts).Seconds()
+}
+
+// isSuccessStatus 2xx(含 206 分段下载)、3xx(含 304 缓存有效)及升级协议均视为成功
+func isSuccessStatus(status int) bool {
+	return status == http.StatusSwitchingProtocols || (status >= http.StatusOK && status < http.StatusBadRequest)
+}
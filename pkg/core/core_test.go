@@ -0,0 +1,138 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/kisun-bit/aio_dashboard/internal/code"
+	"github.com/kisun-bit/aio_dashboard/pkg/trace"
+	"go.uber.org/zap"
+)
+
+func newLifecycleTestMux(t *testing.T, options ...Option) HTTPMixin {
+	t.Helper()
+
+	mux, err := New(zap.NewNop(), options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := mux.Group("/api")
+	g.GET("/ok", func(ctx ContextWrap) { ctx.Payload(map[string]string{"name": "dashboard"}) })
+	g.GET("/fail", func(ctx ContextWrap) { ctx.AbortWithError(ParamBindError(errors.New("bad"))) })
+	g.GET("/panic", func(ctx ContextWrap) { panic("boom") })
+	return mux
+}
+
+func TestNewRequiresLogger(t *testing.T) {
+	if _, err := New(nil); err == nil {
+		t.Fatal("want error without logger")
+	}
+}
+
+func TestLifecycleEnvelope(t *testing.T) {
+	mux := newLifecycleTestMux(t)
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		header   http.Header
+		wantCode int
+		wantBiz  int
+	}{
+		{name: "payload", method: http.MethodGet, path: "/api/ok", wantCode: http.StatusOK, wantBiz: code.OK},
+		{name: "abort", method: http.MethodGet, path: "/api/fail", wantCode: http.StatusBadRequest, wantBiz: code.ParamBindError},
+		{name: "panic", method: http.MethodGet, path: "/api/panic", wantCode: http.StatusInternalServerError, wantBiz: code.ServerError},
+		{name: "unmatched", method: http.MethodGet, path: "/api/missing", wantCode: http.StatusNotFound, wantBiz: code.RouteNotFound},
+		{name: "unmatched method", method: http.MethodPost, path: "/api/ok", wantCode: http.StatusNotFound, wantBiz: code.RouteNotFound},
+		{name: "incoming trace id", method: http.MethodGet, path: "/api/ok", header: http.Header{trace.Header: {"abc123"}}, wantCode: http.StatusOK, wantBiz: code.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(mux, tt.method, tt.path, tt.header)
+			if w.Code != tt.wantCode {
+				t.Fatalf("code = %d, want %d: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+			if !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+				t.Errorf("Content-Type = %q", w.Header().Get("Content-Type"))
+			}
+
+			resp := new(Response)
+			if err := json.Unmarshal(w.Body.Bytes(), resp); err != nil {
+				t.Fatalf("body %q is not the envelope: %v", w.Body.String(), err)
+			}
+			if resp.Code != tt.wantBiz || resp.Message == "" {
+				t.Errorf("envelope = %+v, want code %d", resp, tt.wantBiz)
+			}
+
+			traceID := w.Header().Get(trace.Header)
+			if traceID == "" || resp.TraceID != traceID {
+				t.Errorf("trace id header %q, envelope %q", traceID, resp.TraceID)
+			}
+			if want := tt.header.Get(trace.Header); want != "" && traceID != want {
+				t.Errorf("trace id = %q, want incoming %q", traceID, want)
+			}
+		})
+	}
+}
+
+func TestNoRouteWithStatic(t *testing.T) {
+	mux := newLifecycleTestMux(t, WithStaticFS(fstest.MapFS{
+		"index.html": {Data: []byte("<html>index</html>")},
+		"app.js":     {Data: []byte("console.log(1)")},
+	}))
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		accept   string
+		wantCode int
+		wantBody string
+	}{
+		{"static file", http.MethodGet, "/app.js", "", http.StatusOK, "console.log(1)"},
+		{"spa fallback", http.MethodGet, "/settings", "text/html", http.StatusOK, "index"},
+		{"missing asset", http.MethodGet, "/missing.js", "", http.StatusNotFound, `"code":10116`},
+		{"missing api", http.MethodGet, "/api/missing", "application/json", http.StatusNotFound, `"code":10116`},
+		{"post", http.MethodPost, "/app.js", "", http.StatusNotFound, `"code":10116`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(mux, tt.method, tt.path, http.Header{"Accept": {tt.accept}})
+			if w.Code != tt.wantCode || !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("got %d %q, want %d containing %q", w.Code, w.Body.String(), tt.wantCode, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSharedErrorLanguage(t *testing.T) {
+	shared := Code(code.ParamBindError)
+
+	mux, err := New(zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.Group("/api").GET("/shared", func(ctx ContextWrap) { ctx.AbortWithError(shared) })
+
+	for lang, want := range map[string]string{"en-US": "Invalid parameters", "zh-CN": "参数信息错误"} {
+		w := serve(mux, http.MethodGet, "/api/shared", http.Header{"Accept-Language": {lang}})
+		resp := new(Response)
+		if err := json.Unmarshal(w.Body.Bytes(), resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Message != want {
+			t.Errorf("%s: message = %q, want %q", lang, resp.Message, want)
+		}
+	}
+	if got := shared.Message(); got != "参数信息错误" {
+		t.Errorf("shared error was modified: %q", got)
+	}
+}
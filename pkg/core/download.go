@@ -0,0 +1,100 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kisun-bit/aio_dashboard/internal/code"
+)
+
+const _DownloadName = "_download_"
+
+// downloadProgress 下载进度，下载结束后记录至 Trace
+type downloadProgress struct {
+	Name      string  `json:"name"`      // 文件名
+	Size      int64   `json:"size"`      // 文件大小
+	Range     string  `json:"range"`     // 请求的 Range，为空表示完整下载
+	Expected  int64   `json:"expected"`  // 本次应发送的字节数
+	Sent      int64   `json:"sent"`      // 实际发送的字节数
+	Percent   float64 `json:"percent"`   // 本次发送的完成百分比
+	Completed bool    `json:"completed"` // 本次是否发送完整
+	HTTPCode  int     `json:"http_code"` // 200 / 206 / 304 / 412 / 416
+}
+
+// progressWriter 统计 http.ServeContent 写入的字节数
+type progressWriter struct {
+	gin.ResponseWriter
+	sent int64
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	atomic.AddInt64(&w.sent, int64(n))
+	return n, err
+}
+
+// File 下载文件，name 为下载时的文件名，为空时取 filePath 中的文件名
+func (c *GinContext) File(filePath, name string) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		c.AbortWithError(Code(code.FileNotFound).WithError(err))
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		c.AbortWithError(Code(code.FileNotFound).WithError(fmt.Errorf("%s is not a regular file", filePath)))
+		return
+	}
+
+	if name == "" {
+		name = filepath.Base(filePath)
+	}
+	c.ServeContent(name, info.ModTime(), f)
+}
+
+// ServeContent 下载 content；未设置 ETag 时根据大小与修改时间生成强校验 ETag，以支持 If-Range
+func (c *GinContext) ServeContent(name string, modTime time.Time, content io.ReadSeeker) {
+	size, err := content.Seek(0, io.SeekEnd)
+	if err == nil {
+		_, err = content.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		c.AbortWithError(Code(code.ServerError).WithError(err))
+		return
+	}
+
+	header := c.ctx.Writer.Header()
+	if header.Get("ETag") == "" {
+		header.Set("ETag", fmt.Sprintf(`"%x-%x"`, size, modTime.UnixNano()))
+	}
+	if header.Get("Content-Disposition") == "" {
+		header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	}
+	header.Set("Accept-Ranges", "bytes")
+
+	writer := &progressWriter{ResponseWriter: c.ctx.Writer}
+	progress := &downloadProgress{Name: name, Size: size, Range: c.ctx.GetHeader("Range")}
+	c.ctx.Set(_DownloadName, progress)
+
+	http.ServeContent(writer, c.ctx.Request, name, modTime, content)
+
+	progress.HTTPCode = c.ctx.Writer.Status()
+	progress.Sent = atomic.LoadInt64(&writer.sent)
+	if progress.HTTPCode == http.StatusOK || progress.HTTPCode == http.StatusPartialContent {
+		progress.Expected, _ = strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+		progress.Completed = progress.Sent == progress.Expected
+		if progress.Expected > 0 {
+			progress.Percent = float64(progress.Sent) * 100 / float64(progress.Expected)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kisun-bit/aio_dashboard/internal/proposal"
+	"go.uber.org/zap"
+)
+
+func TestIsSuccessStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		want   bool
+	}{
+		{http.StatusOK, true},
+		{http.StatusCreated, true},
+		{http.StatusPartialContent, true},
+		{http.StatusSwitchingProtocols, true},
+		{http.StatusFound, true},
+		{http.StatusNotModified, true},
+		{http.StatusBadRequest, false},
+		{http.StatusPreconditionFailed, false},
+		{http.StatusRequestedRangeNotSatisfiable, false},
+		{http.StatusInternalServerError, false},
+	}
+
+	for _, tt := range tests {
+		if got := isSuccessStatus(tt.status); got != tt.want {
+			t.Errorf("isSuccessStatus(%d) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestServeContentRange(t *testing.T) {
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	etag := fmt.Sprintf(`"%x-%x"`, 10, modTime.UnixNano())
+
+	var metrics []*proposal.MetricsMessage
+	mux, err := New(zap.NewNop(), WithRecordMetrics(func(msg *proposal.MetricsMessage) { metrics = append(metrics, msg) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.Group("/api").GET("/file", func(ctx ContextWrap) {
+		ctx.ServeContent("report.txt", modTime, strings.NewReader("0123456789"))
+	})
+
+	tests := []struct {
+		name        string
+		header      http.Header
+		wantCode    int
+		wantBody    string
+		wantRange   string
+		wantSuccess bool
+	}{
+		{name: "full", wantCode: http.StatusOK, wantBody: "0123456789", wantSuccess: true},
+		{name: "range", header: http.Header{"Range": {"bytes=2-5"}}, wantCode: http.StatusPartialContent, wantBody: "2345", wantRange: "bytes 2-5/10", wantSuccess: true},
+		{name: "suffix range", header: http.Header{"Range": {"bytes=-3"}}, wantCode: http.StatusPartialContent, wantBody: "789", wantRange: "bytes 7-9/10", wantSuccess: true},
+		{name: "unsatisfiable", header: http.Header{"Range": {"bytes=20-"}}, wantCode: http.StatusRequestedRangeNotSatisfiable, wantRange: "bytes */10"},
+		{name: "if-range match", header: http.Header{"Range": {"bytes=5-"}, "If-Range": {etag}}, wantCode: http.StatusPartialContent, wantBody: "56789", wantRange: "bytes 5-9/10", wantSuccess: true},
+		{name: "if-range mismatch", header: http.Header{"Range": {"bytes=5-"}, "If-Range": {`"stale"`}}, wantCode: http.StatusOK, wantBody: "0123456789", wantSuccess: true},
+		{name: "if-none-match", header: http.Header{"If-None-Match": {etag}}, wantCode: http.StatusNotModified, wantSuccess: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics = nil
+			w := serve(mux, http.MethodGet, "/api/file", tt.header)
+			if w.Code != tt.wantCode {
+				t.Fatalf("code = %d, want %d: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+			if got := w.Header().Get("Content-Range"); got != tt.wantRange {
+				t.Errorf("Content-Range = %q, want %q", got, tt.wantRange)
+			}
+			if got := w.Header().Get("ETag"); got != etag {
+				t.Errorf("ETag = %q, want %q", got, etag)
+			}
+			if len(metrics) != 1 || metrics[0].IsSuccess != tt.wantSuccess {
+				t.Errorf("metrics = %+v, want success %v", metrics, tt.wantSuccess)
+			}
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package core
 
-import "github.com/pkg/errors"
+import (
+	"github.com/kisun-bit/aio_dashboard/internal/code"
+	"github.com/pkg/errors"
+)
 
 var _ BusinessError = (*businessError)(nil)
 
@@ -14,6 +17,9 @@ type BusinessError interface {
 	// WithAlert 设置告警通知
 	WithAlert() BusinessError
 
+	// WithDetails 设置错误明细(如校验失败的字段)，作为返回结构中的 data
+	WithDetails(details interface{}) BusinessError
+
 	// BusinessCode 获取业务码
 	BusinessCode() int
 
@@ -28,22 +34,34 @@ type BusinessError interface {
 
 	// IsAlert 是否开启告警通知
 	IsAlert() bool
+
+	// Details 获取错误明细
+	Details() interface{}
+
+	// withLanguage 返回设置了请求语言的副本，Message 据此返回业务码目录中对应语言的描述；
+	// 不修改原错误，包级共享的 BusinessError 可被并发请求使用
+	withLanguage(lang string) BusinessError
 }
 
 type businessError struct {
-	httpCode     int    // HTTP 状态码
-	businessCode int    // 业务码
-	message      string // 错误描述
-	stackError   error  // 含有堆栈信息的错误
-	isAlert      bool   // 是否告警通知
+	httpCode     int         // HTTP 状态码
+	businessCode int         // 业务码
+	message      string      // 错误描述
+	stackError   error       // 含有堆栈信息的错误
+	isAlert      bool        // 是否告警通知
+	details      interface{} // 错误明细
+	localized    bool        // 描述是否取自业务码目录
+	lang         string      // 请求语言
 }
 
-func Error(httpCode, businessCode int, message string) BusinessError {
+// Code 根据业务码目录创建错误，HTTP 状态码取自目录，描述按请求语言返回
+func Code(businessCode int) BusinessError {
 	return &businessError{
-		httpCode:     httpCode,
+		httpCode:     code.HTTPCode(businessCode),
 		businessCode: businessCode,
-		message:      message,
+		message:      code.Text(businessCode),
 		isAlert:      false,
+		localized:    true,
 	}
 }
 
@@ -59,6 +77,11 @@ func (e *businessError) WithAlert() BusinessError {
 	return e
 }
 
+func (e *businessError) WithDetails(details interface{}) BusinessError {
+	e.details = details
+	return e
+}
+
 func (e *businessError) HTTPCode() int {
 	return e.httpCode
 }
@@ -68,6 +91,11 @@ func (e *businessError) BusinessCode() int {
 }
 
 func (e *businessError) Message() string {
+	if e.localized && e.lang != "" {
+		if message := code.Localize(e.businessCode, e.lang); message != "" {
+			return message
+		}
+	}
 	return e.message
 }
 
@@ -78,3 +106,13 @@ func (e *businessError) StackError() error {
 func (e *businessError) IsAlert() bool {
 	return e.isAlert
 }
+
+func (e *businessError) Details() interface{} {
+	return e.details
+}
+
+func (e *businessError) withLanguage(lang string) BusinessError {
+	localized := *e
+	localized.lang = lang
+	return &localized
+}
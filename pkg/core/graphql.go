@@ -0,0 +1,181 @@
+package core
+
+import (
+	innerctx "context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/kisun-bit/aio_dashboard/internal/code"
+)
+
+// graphRequest GraphQL 请求参数；GET 请求通过 querystring 传递，variables 为 JSON 字符串
+type graphRequest struct {
+	Query         string                 `json:"query" form:"query"`
+	OperationName string                 `json:"operationName" form:"operationName"`
+	Variables     map[string]interface{} `json:"variables" form:"-"`
+}
+
+// MountGraphQL 在 relativePath 上同时注册 GET 与 POST 形式的 GraphQL 入口，handlers 先于 GraphQL 执行(如登录验证)
+func MountGraphQL(group IRoutes, relativePath string, schema graphql.Schema, handlers ...HandlerFunc) {
+	chain := make([]HandlerFunc, 0, len(handlers)+1)
+	chain = append(chain, handlers...)
+	chain = append(chain, GraphQL(schema))
+
+	group.GET(relativePath, chain...)
+	group.POST(relativePath, chain...)
+}
+
+// GraphQL 执行 schema：query / mutation 结果以 GraphPayload 返回；
+// 请求头 Accept 为 text/event-stream 时，以 SSE 推送 subscription 的每一次结果
+func GraphQL(schema graphql.Schema) HandlerFunc {
+	return func(ctx ContextWrap) {
+		req, err := bindGraphRequest(ctx)
+		if err != nil {
+			ctx.AbortWithError(ParamBindError(err))
+			return
+		}
+
+		params := graphql.Params{
+			Schema:         schema,
+			RequestString:  req.Query,
+			VariableValues: req.Variables,
+			OperationName:  req.OperationName,
+			Context:        ctx.RequestContext(),
+		}
+
+		if strings.Contains(ctx.GetHeader("Accept"), "text/event-stream") {
+			subscribeGraph(ctx, params)
+			return
+		}
+
+		ctx.GraphPayload(graphResult(graphql.Do(params)))
+	}
+}
+
+// GraphContext 从 resolver 的 ResolveParams.Context 中取得携带 Trace 与 Logger 的 StdContext
+func GraphContext(ctx innerctx.Context) (StdContext, bool) {
+	std, ok := ctx.(StdContext)
+	return std, ok
+}
+
+// GraphQLError 将 BusinessError 转换为 resolver 可返回的 error，业务码写入 errors[].extensions.code；
+// ctx 为 resolver 的 ResolveParams.Context，错误描述按其中的请求语言返回
+func GraphQLError(ctx innerctx.Context, err BusinessError) error {
+	if std, ok := GraphContext(ctx); ok {
+		err = err.withLanguage(std.Language)
+	}
+	return &graphBusinessError{err: err}
+}
+
+var _ gqlerrors.ExtendedError = (*graphBusinessError)(nil)
+
+type graphBusinessError struct {
+	err BusinessError
+}
+
+func (e *graphBusinessError) Error() string {
+	return e.err.Message()
+}
+
+func (e *graphBusinessError) Extensions() map[string]interface{} {
+	return map[string]interface{}{
+		"code":      e.err.BusinessCode(),
+		"http_code": e.err.HTTPCode(),
+	}
+}
+
+func bindGraphRequest(ctx ContextWrap) (*graphRequest, error) {
+	req := new(graphRequest)
+
+	if ctx.Method() == http.MethodGet {
+		if err := ctx.ShouldBindQuery(req); err != nil {
+			return nil, err
+		}
+		if variables := ctx.Request().URL.Query().Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				return nil, err
+			}
+		}
+		return req, nil
+	}
+
+	if err := ctx.ShouldBindJSON(req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// graphResult 将执行结果转换为 GraphResponse；未携带业务码的错误，
+// 语法或校验错误映射为 ParamBindError，resolver 返回的普通 error 映射为 ServerError
+func graphResult(result *graphql.Result) *GraphResponse {
+	resp := &GraphResponse{
+		Data:       result.Data,
+		Extensions: result.Extensions,
+	}
+
+	for _, fe := range result.Errors {
+		ge := GraphError{
+			Message:    fe.Message,
+			Path:       fe.Path,
+			Extensions: fe.Extensions,
+		}
+		for _, loc := range fe.Locations {
+			ge.Locations = append(ge.Locations, GraphLocation{Line: loc.Line, Column: loc.Column})
+		}
+
+		if _, ok := ge.Extensions["code"]; !ok {
+			if ge.Extensions == nil {
+				ge.Extensions = make(map[string]interface{})
+			}
+
+			businessCode := code.ParamBindError
+			if gqlErr, ok := fe.OriginalError().(*gqlerrors.Error); ok && gqlErr.OriginalError != nil {
+				businessCode = code.ServerError
+			}
+			ge.Extensions["code"] = businessCode
+		}
+
+		resp.Errors = append(resp.Errors, ge)
+	}
+
+	return resp
+}
+
+// subscribeGraph 以 SSE 推送 subscription 结果：每个结果为一个 next 事件，结束时发送 complete 事件
+func subscribeGraph(ctx ContextWrap, params graphql.Params) {
+	results := graphql.Subscribe(params)
+	defer func() {
+		// 客户端断开后继续消费剩余结果，避免 subscription 协程阻塞
+		go func() {
+			for range results {
+			}
+		}()
+	}()
+
+	ctx.SSE(func(stream SSEStream) {
+		for {
+			select {
+			case <-stream.Done():
+				return
+			case result, ok := <-results:
+				if !ok {
+					_ = stream.Send(SSEvent{Event: "complete", Data: ""})
+					return
+				}
+
+				resp := graphResult(result)
+				if resp.Extensions == nil {
+					resp.Extensions = make(map[string]interface{})
+				}
+				resp.Extensions["trace_id"] = traceID(ctx)
+
+				if err := stream.Send(SSEvent{Event: "next", Data: resp}); err != nil {
+					return
+				}
+			}
+		}
+	})
+}
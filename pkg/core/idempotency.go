@@ -0,0 +1,232 @@
+package core
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/kisun-bit/aio_dashboard/configs"
+	"github.com/kisun-bit/aio_dashboard/internal/code"
+	"github.com/kisun-bit/aio_dashboard/pkg/trace"
+	"go.uber.org/zap"
+)
+
+const (
+	// HeaderIdempotencyKey 客户端生成的幂等键，相同的键重试时返回首次的结果
+	HeaderIdempotencyKey = "Idempotency-Key"
+	// HeaderIdempotentReplayed 返回结果为首次请求结果的重放时为 true
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+
+	_IdempotencyName = "_idempotency_"
+
+	// idempotencyLockTTL 处理中标记在路由处理时限之外额外保留的有效期，进程异常退出时标记到期后允许重试
+	idempotencyLockTTL = time.Minute
+	maxIdempotencyKey  = 255
+)
+
+// IdempotencyStore 幂等结果的存储
+type IdempotencyStore interface {
+	// Acquire 以 owner 的身份标记 key 正在处理，返回 false 表示已有相同 key 的请求正在处理
+	Acquire(key, owner string, ttl time.Duration) (bool, error)
+	// Release 清除处理中标记，标记已过期并被其他请求获得时不清除
+	Release(key, owner string)
+	// Load 读取已保存的结果，不存在时返回 false
+	Load(key string) ([]byte, bool, error)
+	// Save 保存结果
+	Save(key string, value []byte, ttl time.Duration) error
+}
+
+// WithIdempotency 对携带 Idempotency-Key 的 POST / PUT / PATCH 请求保证幂等：首次的返回(状态码、统一返回结构及 Header)
+// 保存 ttl 时长，重试时直接返回；相同 key 的请求正在处理时返回 IdempotencyInFlight，请求内容不同时返回 IdempotencyKeyReused。
+// 流式请求体的路由、服务端错误(5xx)、限流(429)及登录或权限校验失败(401、403)的结果不保存
+func WithIdempotency(store IdempotencyStore, ttl time.Duration) Option {
+	return func(opt *option) {
+		if store != nil && ttl > 0 {
+			opt.idempotency = &idempotency{store: store, ttl: ttl}
+		}
+	}
+}
+
+type idempotency struct {
+	store IdempotencyStore
+	ttl   time.Duration
+}
+
+// idempotentRecord 保存的首次返回
+type idempotentRecord struct {
+	Fingerprint string          `json:"fingerprint"` // 请求地址及请求体的 SHA-256
+	HTTPCode    int             `json:"http_code"`
+	Header      http.Header     `json:"header"`
+	Body        json.RawMessage `json:"body"`
+}
+
+// idempotentRequest 本次请求的幂等信息，记录至 Trace
+type idempotentRequest struct {
+	Key      string `json:"key"`
+	Replayed bool   `json:"replayed"`
+
+	storeKey    string
+	owner       string
+	fingerprint string
+	record      *idempotentRecord
+}
+
+func isIdempotentMethod(method string) bool {
+	return method == http.MethodPost || method == http.MethodPut || method == http.MethodPatch
+}
+
+// begin 返回 nil 表示本次请求无需幂等处理或已终止(重放、处理中、键被复用)，否则须在渲染后调用 finish
+func (i *idempotency) begin(ctx ContextWrap, routeOpt *routeOption) *idempotentRequest {
+	c := ctx.(*GinContext).ctx
+
+	key := c.GetHeader(HeaderIdempotencyKey)
+	if i == nil || key == "" || routeOpt.streaming || !isIdempotentMethod(c.Request.Method) {
+		return nil
+	}
+	if len(key) > maxIdempotencyKey {
+		ctx.AbortWithError(ParamBindError(errors.New("idempotency key too long")))
+		return nil
+	}
+
+	// 幂等键的作用范围为同一登录 Token 下的同一路由
+	scope := sha256.Sum256([]byte(c.Request.Method + " " + c.FullPath() + "\n" + c.GetHeader(configs.HeaderLoginToken) + "\n" + key))
+	content := sha256.Sum256(append([]byte(c.Request.URL.RequestURI()+"\n"), ctx.RawData()...))
+	req := &idempotentRequest{
+		Key:         key,
+		storeKey:    hex.EncodeToString(scope[:]),
+		fingerprint: hex.EncodeToString(content[:]),
+	}
+	c.Set(_IdempotencyName, req)
+
+	if i.load(ctx, req) {
+		return nil
+	}
+
+	owner := make([]byte, 16)
+	if _, err := rand.Read(owner); err != nil {
+		ctx.AbortWithError(Code(code.ServerError).WithError(err))
+		return nil
+	}
+	req.owner = hex.EncodeToString(owner)
+
+	acquired, err := i.store.Acquire(req.storeKey, req.owner, idempotencyLockTimeout(routeOpt.timeout))
+	if err != nil {
+		ctx.AbortWithError(Code(code.ServerError).WithError(err))
+		return nil
+	}
+	if !acquired {
+		ctx.SetHeader("Retry-After", "1")
+		ctx.AbortWithError(Code(code.IdempotencyInFlight).WithError(errors.New("idempotency key in flight: " + key)))
+		return nil
+	}
+
+	// 首次读取与获得处理权之间，持有标记的请求可能已保存结果并清除标记，此时重放该结果而非再次处理
+	if i.load(ctx, req) {
+		i.store.Release(req.storeKey, req.owner)
+		return nil
+	}
+	return req
+}
+
+// load 读取已保存的结果，存在时重放并返回 true；读取失败时按未保存处理
+func (i *idempotency) load(ctx ContextWrap, req *idempotentRequest) bool {
+	raw, ok, err := i.store.Load(req.storeKey)
+	if err != nil {
+		ctx.Logger().Warn("load idempotent record failed", zap.String("key", req.Key), zap.Error(err))
+		return false
+	}
+	if ok {
+		i.replay(ctx, req, raw)
+	}
+	return ok
+}
+
+// replay 终止请求并由 render 返回首次请求的结果，请求内容不同时返回 IdempotencyKeyReused
+func (i *idempotency) replay(ctx ContextWrap, req *idempotentRequest, raw []byte) {
+	record := new(idempotentRecord)
+	if err := json.Unmarshal(raw, record); err != nil {
+		ctx.AbortWithError(Code(code.ServerError).WithError(err))
+		return
+	}
+	if record.Fingerprint != req.fingerprint {
+		ctx.AbortWithError(Code(code.IdempotencyKeyReused).WithError(errors.New("idempotency key reused: " + req.Key)))
+		return
+	}
+
+	c := ctx.(*GinContext).ctx
+	header := c.Writer.Header()
+	for key, values := range record.Header {
+		header[key] = values
+	}
+	header.Set(HeaderIdempotentReplayed, "true")
+
+	req.Replayed = true
+	req.record = record
+	c.Abort()
+}
+
+// finish 保存本次的返回并清除处理中标记，body 为 render 输出的统一返回结构
+func (i *idempotency) finish(ctx ContextWrap, req *idempotentRequest, body interface{}) {
+	defer i.store.Release(req.storeKey, req.owner)
+
+	c := ctx.(*GinContext).ctx
+	status := c.Writer.Status()
+	switch {
+	case status >= http.StatusInternalServerError, status == http.StatusTooManyRequests,
+		status == http.StatusUnauthorized, status == http.StatusForbidden:
+		return
+	}
+
+	switch body.(type) {
+	case *Response, *GraphResponse:
+	default:
+		return
+	}
+
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return
+	}
+
+	header := make(http.Header)
+	for key, values := range c.Writer.Header() {
+		switch key {
+		case "Content-Length", "Content-Encoding", "Vary", "Retry-After", http.CanonicalHeaderKey(trace.Header):
+		default:
+			header[key] = values
+		}
+	}
+
+	record, err := json.Marshal(&idempotentRecord{
+		Fingerprint: req.fingerprint,
+		HTTPCode:    status,
+		Header:      header,
+		Body:        raw,
+	})
+	if err == nil {
+		err = i.store.Save(req.storeKey, record, i.ttl)
+	}
+	if err != nil {
+		ctx.Logger().Warn("save idempotent record failed", zap.String("key", req.Key), zap.Error(err))
+	}
+}
+
+// idempotentReplay 需要重放的首次返回，未命中时返回 nil
+func idempotentReplay(c *GinContext) *idempotentRecord {
+	if v, ok := c.ctx.Get(_IdempotencyName); ok {
+		return v.(*idempotentRequest).record
+	}
+	return nil
+}
+
+// idempotencyLockTimeout 处理中标记的有效期不短于路由的处理时限，避免请求仍在处理时标记到期而被重复执行
+func idempotencyLockTimeout(routeTimeout time.Duration) time.Duration {
+	if routeTimeout > 0 {
+		return routeTimeout + idempotencyLockTTL
+	}
+	return idempotencyLockTTL
+}
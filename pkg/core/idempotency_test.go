@@ -0,0 +1,198 @@
+package core
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kisun-bit/aio_dashboard/internal/code"
+	"go.uber.org/zap"
+)
+
+// memStore 进程内的幂等存储，afterAcquire 在获得处理权后执行，用于模拟并发请求
+type memStore struct {
+	mux          sync.Mutex
+	locks        map[string]string
+	records      map[string][]byte
+	lockTTL      time.Duration
+	afterAcquire func(key string)
+}
+
+func newMemStore() *memStore {
+	return &memStore{locks: make(map[string]string), records: make(map[string][]byte)}
+}
+
+func (s *memStore) Acquire(key, owner string, ttl time.Duration) (bool, error) {
+	s.mux.Lock()
+	s.lockTTL = ttl
+	if _, ok := s.locks[key]; ok {
+		s.mux.Unlock()
+		return false, nil
+	}
+	s.locks[key] = owner
+	s.mux.Unlock()
+
+	if s.afterAcquire != nil {
+		s.afterAcquire(key)
+	}
+	return true, nil
+}
+
+func (s *memStore) Release(key, owner string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.locks[key] == owner {
+		delete(s.locks, key)
+	}
+}
+
+func (s *memStore) Load(key string) ([]byte, bool, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	value, ok := s.records[key]
+	return value, ok, nil
+}
+
+func (s *memStore) Save(key string, value []byte, _ time.Duration) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.records[key] = value
+	return nil
+}
+
+func newIdempotencyTestMux(t *testing.T, store IdempotencyStore, calls *int) HTTPMixin {
+	t.Helper()
+
+	mux, err := New(zap.NewNop(), WithIdempotency(store, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := mux.Group("/api")
+	g.POST("/orders", func(ctx ContextWrap) {
+		*calls++
+		ctx.Payload(map[string]int{"order": *calls})
+	})
+	g.POST("/denied", func(ctx ContextWrap) {
+		*calls++
+		ctx.AbortWithError(Code(code.AuthorizationError))
+	})
+	return mux
+}
+
+func post(mux http.Handler, path, key, body string) (int, string, string) {
+	req, _ := http.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set(HeaderIdempotencyKey, key)
+	w := serveRequest(mux, req)
+	return w.Code, w.Header().Get(HeaderIdempotentReplayed), w.Body.String()
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	calls := 0
+	mux := newIdempotencyTestMux(t, newMemStore(), &calls)
+
+	code1, replayed1, body1 := post(mux, "/api/orders", "k1", `{"a":1}`)
+	code2, replayed2, body2 := post(mux, "/api/orders", "k1", `{"a":1}`)
+	if calls != 1 || code1 != code2 || body1 != body2 || replayed1 != "" || replayed2 != "true" {
+		t.Fatalf("calls=%d first=(%d %q %s) second=(%d %q %s)", calls, code1, replayed1, body1, code2, replayed2, body2)
+	}
+
+	if status, _, _ := post(mux, "/api/orders", "k1", `{"a":2}`); status == http.StatusOK || calls != 1 {
+		t.Errorf("reused key with different body: code=%d calls=%d", status, calls)
+	}
+	if post(mux, "/api/orders", "k2", `{"a":1}`); calls != 2 {
+		t.Errorf("new key should be processed, calls=%d", calls)
+	}
+}
+
+func TestIdempotencyInFlight(t *testing.T) {
+	calls := 0
+	store := newMemStore()
+	mux := newIdempotencyTestMux(t, store, &calls)
+
+	store.afterAcquire = func(string) {
+		store.afterAcquire = nil
+		if status, _, _ := post(mux, "/api/orders", "k", `{}`); status != http.StatusConflict {
+			t.Errorf("concurrent request code = %d, want %d", status, http.StatusConflict)
+		}
+	}
+	if status, _, _ := post(mux, "/api/orders", "k", `{}`); status != http.StatusOK || calls != 1 {
+		t.Fatalf("first request code=%d calls=%d", status, calls)
+	}
+	if len(store.locks) != 0 {
+		t.Errorf("lock not released: %v", store.locks)
+	}
+}
+
+func TestIdempotencyReloadAfterAcquire(t *testing.T) {
+	calls := 0
+	store := newMemStore()
+	mux := newIdempotencyTestMux(t, store, &calls)
+
+	// 首次读取未命中后、获得处理权前，另一个请求已完成并保存结果
+	store.afterAcquire = func(key string) {
+		store.afterAcquire = nil
+		store.mux.Lock()
+		owner := store.locks[key]
+		delete(store.locks, key)
+		store.mux.Unlock()
+
+		post(mux, "/api/orders", "k", `{}`)
+
+		store.mux.Lock()
+		store.locks[key] = owner
+		store.mux.Unlock()
+	}
+	_, replayed, _ := post(mux, "/api/orders", "k", `{}`)
+	if calls != 1 || replayed != "true" {
+		t.Fatalf("calls=%d replayed=%q, want handler run once and result replayed", calls, replayed)
+	}
+	if len(store.locks) != 0 {
+		t.Errorf("lock not released: %v", store.locks)
+	}
+}
+
+func TestIdempotencySkipsAuthFailures(t *testing.T) {
+	calls := 0
+	store := newMemStore()
+	mux := newIdempotencyTestMux(t, store, &calls)
+
+	for i := 0; i < 2; i++ {
+		if status, replayed, _ := post(mux, "/api/denied", "k", `{}`); status != http.StatusUnauthorized || replayed != "" {
+			t.Fatalf("request %d code=%d replayed=%q", i, status, replayed)
+		}
+	}
+	if calls != 2 || len(store.records) != 0 {
+		t.Errorf("calls=%d records=%d, want 401 not saved", calls, len(store.records))
+	}
+}
+
+func TestIdempotencyLockCoversRouteTimeout(t *testing.T) {
+	store := newMemStore()
+	mux, err := New(zap.NewNop(), WithIdempotency(store, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := mux.Group("/api")
+	g.POST("/orders", func(ctx ContextWrap) { ctx.Payload(nil) })
+	g.With(WithTimeout(10*time.Minute)).POST("/import", func(ctx ContextWrap) { ctx.Payload(nil) })
+
+	tests := []struct {
+		path string
+		want time.Duration
+	}{
+		{"/api/orders", idempotencyLockTTL},
+		{"/api/import", 10*time.Minute + idempotencyLockTTL},
+	}
+	for _, tt := range tests {
+		if code, _, body := post(mux, tt.path, "key-"+tt.path, "{}"); code != http.StatusOK {
+			t.Fatalf("%s: %d %s", tt.path, code, body)
+		}
+		if store.lockTTL != tt.want {
+			t.Errorf("%s: lock ttl = %s, want %s", tt.path, store.lockTTL, tt.want)
+		}
+	}
+}
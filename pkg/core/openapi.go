@@ -0,0 +1,361 @@
+package core
+
+import (
+	"embed"
+	"encoding/json"
+	"html"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kisun-bit/aio_dashboard/internal/code"
+)
+
+// Doc 接口文档描述，通过 WithDoc 附加至路由，用于生成 OpenAPI 文档
+type Doc struct {
+	Summary     string      // 接口摘要
+	Description string      // 接口详细描述
+	Tags        []string    // 分组标签
+	Auth        bool        // 是否需要登录 Token
+	Query       interface{} // ShouldBindQuery 绑定的结构体，tag: `form:"xxx"`
+	Form        interface{} // ShouldBindForm / ShouldBindPostForm 绑定的结构体，tag: `form:"xxx"`
+	JSON        interface{} // ShouldBindJSON 绑定的结构体，tag: `json:"xxx"`
+	URI         interface{} // ShouldBindURI 绑定的结构体，tag: `uri:"xxx"`
+	Response    interface{} // Payload 返回的数据，即统一返回结构中的 data
+}
+
+// OpenAPIInfo OpenAPI 文档基本信息
+type OpenAPIInfo struct {
+	Title       string // 文档标题
+	Version     string // 接口版本
+	Description string // 文档描述
+	TokenHeader string // 登录 Token 所在的 Header，对应安全定义 LoginToken
+}
+
+// swaggerUI 固定版本的 swagger-ui-dist，版本见 swagger-ui/README.md
+//
+//go:embed swagger-ui/swagger-ui.css swagger-ui/swagger-ui-bundle.js
+var swaggerUI embed.FS
+
+const swaggerUIHTML = `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+  <meta charset="UTF-8">
+  <title>{{TITLE}}</title>
+  <link rel="stylesheet" href="{{ASSETS}}/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="{{ASSETS}}/swagger-ui-bundle.js"></script>
+  <script src="{{ASSETS}}/swagger-initializer.js"></script>
+</body>
+</html>`
+
+// serveOpenAPI 在 basePath 下提供 Swagger UI 及 openapi.json；文档在每次请求时根据已注册的路由生成。
+// Swagger UI 的脚本及样式随程序嵌入，初始化脚本单独提供，CSP 无需放开外部来源及内联脚本
+func (m *mux) serveOpenAPI(basePath string, info OpenAPIInfo) {
+	specPath := joinPaths(basePath, "openapi.json")
+	assetsPath := joinPaths(basePath, "assets")
+
+	page := strings.NewReplacer("{{TITLE}}", html.EscapeString(info.Title), "{{ASSETS}}", assetsPath).Replace(swaggerUIHTML)
+	specURL, _ := json.Marshal(specPath)
+	initializer := `window.ui = SwaggerUIBundle({url: ` + string(specURL) + `, dom_id: "#swagger-ui", persistAuthorization: true});`
+
+	m.engine.GET(basePath, wrapHandlers(DisableTraceLog, DisableRecordMetrics, func(ctx ContextWrap) {
+		ctx.(*GinContext).ctx.Data(http.StatusOK, "text/html; charset=utf-8", []byte(page))
+	})...)
+
+	m.engine.GET(specPath, wrapHandlers(DisableTraceLog, DisableRecordMetrics, func(ctx ContextWrap) {
+		ctx.(*GinContext).ctx.JSON(http.StatusOK, buildOpenAPI(info, m.routes.list()))
+	})...)
+
+	m.engine.GET(joinPaths(assetsPath, "swagger-initializer.js"), wrapHandlers(DisableTraceLog, DisableRecordMetrics, func(ctx ContextWrap) {
+		ctx.(*GinContext).ctx.Data(http.StatusOK, "text/javascript; charset=utf-8", []byte(initializer))
+	})...)
+
+	for _, name := range []string{"swagger-ui.css", "swagger-ui-bundle.js"} {
+		name := name
+		m.engine.GET(joinPaths(assetsPath, name), wrapHandlers(DisableTraceLog, DisableRecordMetrics, func(ctx ContextWrap) {
+			ctx.(*GinContext).ctx.FileFromFS("swagger-ui/"+name, http.FS(swaggerUI))
+		})...)
+	}
+}
+
+type object = map[string]interface{}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	pathParamRegexp   = regexp.MustCompile(`[:*]([^/]+)`)
+	schemaNameReplace = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// openAPIGenerator 将路由及绑定结构体转换为 OpenAPI 3 文档，命名结构体输出至 components.schemas
+type openAPIGenerator struct {
+	schemas object
+	names   map[reflect.Type]string
+}
+
+func buildOpenAPI(info OpenAPIInfo, routes []*routeOption) object {
+	g := &openAPIGenerator{
+		schemas: make(object),
+		names:   make(map[reflect.Type]string),
+	}
+
+	paths := make(map[string]object)
+	for _, route := range routes {
+		if route.method == http.MethodConnect {
+			continue
+		}
+
+		p := pathParamRegexp.ReplaceAllString(route.path, "{$1}")
+		if paths[p] == nil {
+			paths[p] = make(object)
+		}
+		paths[p][strings.ToLower(route.method)] = g.operation(route)
+	}
+
+	return object{
+		"openapi": "3.0.3",
+		"info": object{
+			"title":       info.Title,
+			"version":     info.Version,
+			"description": info.Description,
+		},
+		"paths": paths,
+		// 业务码目录，与 /system/codes 接口输出一致
+		"x-business-codes": code.All(),
+		"components": object{
+			"schemas": g.schemas,
+			"securitySchemes": object{
+				"LoginToken": object{"type": "apiKey", "in": "header", "name": info.TokenHeader},
+			},
+		},
+	}
+}
+
+func (g *openAPIGenerator) operation(route *routeOption) object {
+	doc := route.doc
+	if doc == nil {
+		doc = new(Doc)
+	}
+
+	op := object{
+		"responses": object{
+			"200": object{
+				"description": "成功",
+				"content":     object{"application/json": object{"schema": g.envelope(doc.Response)}},
+			},
+			"default": object{
+				"description": "失败，code 为业务码",
+				"content":     object{"application/json": object{"schema": g.envelope(nil)}},
+			},
+		},
+	}
+	if doc.Summary != "" {
+		op["summary"] = doc.Summary
+	}
+	if doc.Description != "" {
+		op["description"] = doc.Description
+	}
+	if len(doc.Tags) > 0 {
+		op["tags"] = doc.Tags
+	}
+	if route.version != "" {
+		op["x-api-version"] = route.version
+	}
+	if route.deprecation != nil {
+		op["deprecated"] = true
+		if !route.deprecation.sunset.IsZero() {
+			op["x-sunset"] = route.deprecation.sunset.UTC().Format(time.RFC3339)
+		}
+	}
+	if doc.Auth {
+		op["security"] = []object{{"LoginToken": []string{}}}
+	}
+
+	var params []object
+	if doc.URI != nil {
+		params = append(params, g.parameters(doc.URI, "uri", "path")...)
+	} else {
+		for _, match := range pathParamRegexp.FindAllStringSubmatch(route.path, -1) {
+			params = append(params, object{
+				"name": match[1], "in": "path", "required": true, "schema": object{"type": "string"},
+			})
+		}
+	}
+	if doc.Query != nil {
+		params = append(params, g.parameters(doc.Query, "form", "query")...)
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	switch {
+	case doc.JSON != nil:
+		op["requestBody"] = object{
+			"required": true,
+			"content":  object{"application/json": object{"schema": g.schema(reflect.TypeOf(doc.JSON))}},
+		}
+	case doc.Form != nil:
+		op["requestBody"] = object{
+			"required": true,
+			"content": object{"application/x-www-form-urlencoded": object{
+				"schema": g.structSchema(indirectType(reflect.TypeOf(doc.Form)), "form"),
+			}},
+		}
+	}
+
+	return op
+}
+
+// envelope 统一返回结构的 schema，data 为 Payload 的结构
+func (g *openAPIGenerator) envelope(data interface{}) object {
+	dataSchema := object{"nullable": true}
+	if data != nil {
+		dataSchema = g.schema(reflect.TypeOf(data))
+	}
+
+	return object{
+		"type": "object",
+		"properties": object{
+			"code":     object{"type": "integer", "description": "业务码，0 表示成功"},
+			"message":  object{"type": "string", "description": "描述信息"},
+			"data":     dataSchema,
+			"trace_id": object{"type": "string", "description": "链路ID"},
+		},
+	}
+}
+
+// parameters 将结构体中带有 tagKey 的字段转换为 in 位置的参数
+func (g *openAPIGenerator) parameters(obj interface{}, tagKey, in string) []object {
+	var params []object
+	eachField(indirectType(reflect.TypeOf(obj)), tagKey, func(name string, field reflect.StructField) {
+		params = append(params, object{
+			"name":     name,
+			"in":       in,
+			"required": in == "path" || isRequired(field),
+			"schema":   g.schema(field.Type),
+		})
+	})
+	return params
+}
+
+func (g *openAPIGenerator) schema(t reflect.Type) object {
+	t = indirectType(t)
+	if t == timeType {
+		return object{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return object{"type": "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return object{"type": "integer", "format": "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return object{"type": "integer", "format": "int64"}
+	case reflect.Float32:
+		return object{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return object{"type": "number", "format": "double"}
+	case reflect.String:
+		return object{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return object{"type": "string", "format": "byte"}
+		}
+		return object{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return object{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t, "json")
+		}
+		return object{"$ref": "#/components/schemas/" + g.component(t)}
+	default:
+		return object{}
+	}
+}
+
+// component 注册命名结构体，重名时以包路径区分
+func (g *openAPIGenerator) component(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	name := schemaNameReplace.ReplaceAllString(t.Name(), "_")
+	if _, exists := g.schemas[name]; exists {
+		name = schemaNameReplace.ReplaceAllString(t.PkgPath()+"."+t.Name(), "_")
+	}
+
+	// 先占位，以支持自引用的结构体
+	g.names[t] = name
+	g.schemas[name] = object{}
+	g.schemas[name] = g.structSchema(t, "json")
+
+	return name
+}
+
+func (g *openAPIGenerator) structSchema(t reflect.Type, tagKey string) object {
+	properties := make(object)
+	var required []string
+
+	eachField(t, tagKey, func(name string, field reflect.StructField) {
+		properties[name] = g.schema(field.Type)
+		if isRequired(field) {
+			required = append(required, name)
+		}
+	})
+
+	schema := object{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// eachField 遍历结构体中可导出的字段，匿名嵌入的结构体字段展开处理；字段名取自 tagKey，缺省时使用字段名
+func eachField(t reflect.Type, tagKey string, fn func(name string, field reflect.StructField)) {
+	if t.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get(tagKey)
+		if tag == "-" {
+			continue
+		}
+
+		if field.Anonymous && tag == "" && indirectType(field.Type).Kind() == reflect.Struct {
+			eachField(indirectType(field.Type), tagKey, fn)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		name := strings.Split(tag, ",")[0]
+		if name == "" {
+			name = field.Name
+		}
+		fn(name, field)
+	}
+}
+
+func isRequired(field reflect.StructField) bool {
+	for _, rule := range strings.Split(field.Tag.Get("binding"), ",") {
+		if rule == "required" {
+			return true
+		}
+	}
+	return false
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
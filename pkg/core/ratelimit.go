@@ -0,0 +1,129 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kisun-bit/aio_dashboard/internal/code"
+	"go.uber.org/zap"
+)
+
+const _RateLimiterName = "_rate_limiter_"
+
+// RateLimiter 令牌桶限流器，key 相同的请求共享一个桶
+type RateLimiter interface {
+	// Allow 从 key 的令牌桶中取出一个令牌，桶的容量及每秒补充的令牌数均为 rate；
+	// 令牌不足时返回 false 及预计可重试的等待时长
+	Allow(key string, rate int) (bool, time.Duration, error)
+}
+
+// RateLimit 每秒请求数上限，<= 0 表示不限制
+type RateLimit struct {
+	Global   int // 全部请求
+	PerIP    int // 每个客户端 IP
+	PerUser  int // 每个登录用户，登录验证(WrapAuthHandler)通过后检查
+	PerAlias int // 每个路由别名(AliasForRecordMetrics)，未设置别名的路由不检查
+}
+
+// WithRateLimit 开启限流，超出限制时返回 TooManyRequests 及 Retry-After；限流器异常时放行
+func WithRateLimit(limiter RateLimiter, limit RateLimit) Option {
+	return func(opt *option) {
+		if limiter != nil {
+			opt.rateLimiter = &rateLimiter{limiter: limiter, limit: limit}
+		}
+	}
+}
+
+type rateLimiter struct {
+	limiter RateLimiter
+	limit   RateLimit
+}
+
+func rateLimiterOf(ctx ContextWrap) *rateLimiter {
+	if v, ok := ctx.(*GinContext).ctx.Get(_RateLimiterName); ok {
+		return v.(*rateLimiter)
+	}
+	return nil
+}
+
+// allow 检查 key 是否超出 rate，超出时设置 Retry-After 并以 TooManyRequests 终止请求
+func (r *rateLimiter) allow(ctx ContextWrap, key string, rate int) bool {
+	if r == nil || rate <= 0 {
+		return true
+	}
+
+	ok, retryAfter, err := r.limiter.Allow(key, rate)
+	if err != nil {
+		ctx.Logger().Warn("rate limiter failed", zap.String("key", key), zap.Error(err))
+		return true
+	}
+	if ok {
+		return true
+	}
+
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	ctx.SetHeader("Retry-After", strconv.Itoa(seconds))
+	ctx.AbortWithError(Code(code.TooManyRequests).WithError(fmt.Errorf("rate limit exceeded: %s", key)))
+	return false
+}
+
+// bucket 令牌桶，tokens 为 last 时刻的令牌数
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// localLimiter 进程内的令牌桶限流器，适用于单节点部署
+type localLimiter struct {
+	mux       sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewLocalLimiter 创建进程内的令牌桶限流器，长时间未使用的桶会被定期清理
+func NewLocalLimiter() RateLimiter {
+	return &localLimiter{buckets: make(map[string]*bucket), lastSweep: time.Now()}
+}
+
+func (l *localLimiter) Allow(key string, rate int) (bool, time.Duration, error) {
+	now := time.Now()
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rate), last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(rate), b.tokens+now.Sub(b.last).Seconds()*float64(rate))
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0, nil
+	}
+	return false, time.Duration((1 - b.tokens) / float64(rate) * float64(time.Second)), nil
+}
+
+// sweep 每分钟清理一次超过一分钟未使用的桶，这些桶早已补满，清理后与新建等价
+func (l *localLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= time.Minute {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
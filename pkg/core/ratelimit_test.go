@@ -0,0 +1,122 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestLocalLimiter(t *testing.T) {
+	limiter := NewLocalLimiter()
+
+	for i := 0; i < 3; i++ {
+		if ok, _, _ := limiter.Allow("a", 3); !ok {
+			t.Fatalf("request %d rejected", i)
+		}
+	}
+	ok, retryAfter, _ := limiter.Allow("a", 3)
+	if ok || retryAfter <= 0 {
+		t.Fatalf("want rejected with retryAfter, got ok=%v retryAfter=%v", ok, retryAfter)
+	}
+	if ok, _, _ = limiter.Allow("b", 3); !ok {
+		t.Error("other key should have its own bucket")
+	}
+}
+
+// countingBody 记录请求体是否被读取
+type countingBody struct {
+	*strings.Reader
+	read bool
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	b.read = true
+	return b.Reader.Read(p)
+}
+
+func (b *countingBody) Close() error { return nil }
+
+func TestRateLimitClientIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		proxies []string
+		remotes []string
+		forward []string
+		want    []int
+	}{
+		{
+			name:    "forwarded header ignored without trusted proxies",
+			remotes: []string{"10.0.0.1:1000", "10.0.0.1:1001"},
+			forward: []string{"1.1.1.1", "2.2.2.2"},
+			want:    []int{http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:    "forwarded header from trusted proxy",
+			proxies: []string{"10.0.0.0/8"},
+			remotes: []string{"10.0.0.1:1000", "10.0.0.1:1001"},
+			forward: []string{"1.1.1.1", "2.2.2.2"},
+			want:    []int{http.StatusOK, http.StatusOK},
+		},
+		{
+			name:    "forwarded header from untrusted peer",
+			proxies: []string{"10.0.0.0/8"},
+			remotes: []string{"192.168.0.1:1000", "192.168.0.1:1001"},
+			forward: []string{"1.1.1.1", "2.2.2.2"},
+			want:    []int{http.StatusOK, http.StatusTooManyRequests},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux, err := New(zap.NewNop(),
+				WithTrustedProxies(tt.proxies...),
+				WithRateLimit(NewLocalLimiter(), RateLimit{PerIP: 1}),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mux.Group("/api").GET("/ping", func(ctx ContextWrap) { ctx.Payload("pong") })
+
+			for i := range tt.remotes {
+				req := httptest.NewRequest(http.MethodGet, "/api/ping", nil)
+				req.RemoteAddr = tt.remotes[i]
+				req.Header.Set("X-Forwarded-For", tt.forward[i])
+				if w := serveRequest(mux, req); w.Code != tt.want[i] {
+					t.Errorf("request %d code = %d, want %d", i, w.Code, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitBeforeReadingBody(t *testing.T) {
+	mux, err := New(zap.NewNop(), WithRateLimit(NewLocalLimiter(), RateLimit{Global: 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.Group("/api").POST("/echo", func(ctx ContextWrap) { ctx.Payload(string(ctx.RawData())) })
+
+	for i, want := range []struct {
+		code int
+		read bool
+	}{{http.StatusOK, true}, {http.StatusTooManyRequests, false}} {
+		body := &countingBody{Reader: strings.NewReader(`{"a":1}`)}
+		req := httptest.NewRequest(http.MethodPost, "/api/echo", body)
+		w := serveRequest(mux, req)
+		if w.Code != want.code || body.read != want.read {
+			t.Errorf("request %d code = %d read = %v, want %d %v", i, w.Code, body.read, want.code, want.read)
+		}
+		if want.code == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+			t.Error("Retry-After missing")
+		}
+	}
+}
+
+func TestInvalidTrustedProxies(t *testing.T) {
+	if _, err := New(zap.NewNop(), WithTrustedProxies("not-an-ip")); err == nil {
+		t.Error("want error for invalid trusted proxy")
+	}
+}
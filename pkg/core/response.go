@@ -0,0 +1,135 @@
+package core
+
+import (
+	"net/http"
+
+	"github.com/kisun-bit/aio_dashboard/internal/code"
+	"github.com/kisun-bit/aio_dashboard/pkg/trace"
+)
+
+// Response 统一返回结构
+type Response struct {
+	Code    int         `json:"code"`     // 业务码，0 表示成功
+	Message string      `json:"message"`  // 描述信息
+	Data    interface{} `json:"data"`     // 返回数据
+	TraceID string      `json:"trace_id"` // 链路ID
+}
+
+// GraphResponse GraphQL 返回结构 (遵循 GraphQL over HTTP 规范)
+type GraphResponse struct {
+	Data       interface{}            `json:"data"`                 // 返回数据
+	Errors     []GraphError           `json:"errors,omitempty"`     // 错误列表
+	Extensions map[string]interface{} `json:"extensions,omitempty"` // 扩展信息
+}
+
+// GraphError GraphQL 错误结构
+type GraphError struct {
+	Message    string                 `json:"message"`              // 错误描述
+	Locations  []GraphLocation        `json:"locations,omitempty"`  // 出错位置
+	Path       []interface{}          `json:"path,omitempty"`       // 出错字段路径
+	Extensions map[string]interface{} `json:"extensions,omitempty"` // 扩展信息，如业务码
+}
+
+// GraphLocation GraphQL 语句中的位置
+type GraphLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// render 输出 handlers 通过 Payload / GraphPayload / AbortWithError 设置的结果，返回实际输出的内容；
+// 成功返回时按路由配置处理 ETag 与 Cache-Control，超过 compressSize 字节时压缩
+func render(ctx ContextWrap, routeOpt *routeOption, compressSize int) interface{} {
+	gc := ctx.(*GinContext)
+	c := gc.ctx
+
+	// handler 已直接写入响应(如 HTML、Redirect)时不再追加输出；SSE 推送、WebSocket 连接及文件下载记录其概况
+	if c.Writer.Written() {
+		for _, key := range []string{_SSEName, _WebSocketName, _DownloadName} {
+			if summary, ok := c.Get(key); ok {
+				return summary
+			}
+		}
+		return nil
+	}
+
+	// 携带 Idempotency-Key 的重试直接返回首次的结果
+	if record := idempotentReplay(gc); record != nil {
+		writeJSON(c, record.HTTPCode, record.Body, compressSize)
+		return record.Body
+	}
+
+	if c.IsAborted() {
+		err := ctx.abortError()
+		if err == nil {
+			return nil
+		}
+
+		httpCode := err.HTTPCode()
+		if httpCode == 0 {
+			httpCode = http.StatusInternalServerError
+		}
+
+		resp := &Response{
+			Code:    err.BusinessCode(),
+			Message: err.Message(),
+			Data:    err.Details(),
+			TraceID: traceID(ctx),
+		}
+		writeJSON(c, httpCode, resp, compressSize)
+		return resp
+	}
+
+	if payload := ctx.getGraphPayload(); payload != nil {
+		resp := graphResponse(payload)
+		if resp.Extensions == nil {
+			resp.Extensions = make(map[string]interface{})
+		}
+		if cacheResponse(gc, routeOpt, resp.Data) {
+			return nil
+		}
+
+		resp.Extensions["trace_id"] = traceID(ctx)
+		writeJSON(c, http.StatusOK, resp, compressSize)
+		return resp
+	}
+
+	if payload := ctx.getPayload(); payload != nil {
+		if cacheResponse(gc, routeOpt, payload) {
+			return nil
+		}
+
+		resp := &Response{
+			Code:    code.OK,
+			Message: code.Localize(code.OK, ctx.Language()),
+			Data:    payload,
+			TraceID: traceID(ctx),
+		}
+		writeJSON(c, http.StatusOK, resp, compressSize)
+		return resp
+	}
+
+	// SetResourceVersion 判定缓存有效后 handler 未设置 Payload 直接返回
+	if gc.resourceETag() != "" {
+		cacheResponse(gc, routeOpt, nil)
+	}
+	return nil
+}
+
+// graphResponse 将 GraphPayload 转换为 GraphQL 返回结构，非 GraphResponse 类型的值作为 data 返回
+func graphResponse(payload interface{}) *GraphResponse {
+	switch resp := payload.(type) {
+	case *GraphResponse:
+		return resp
+	case GraphResponse:
+		return &resp
+	default:
+		return &GraphResponse{Data: payload}
+	}
+}
+
+func traceID(ctx ContextWrap) string {
+	if t := ctx.Trace(); t != nil {
+		return t.ID()
+	}
+	return ctx.ResponseWriter().Header().Get(trace.Header)
+}
@@ -0,0 +1,149 @@
+package core
+
+import (
+	"net/http"
+	"path"
+	"sync"
+	"time"
+)
+
+// RouteOption 路由级配置，通过 RouterGroup.With 附加至随后注册的路由
+type RouteOption func(*routeOption)
+
+type routeOption struct {
+	method       string
+	path         string
+	timeout      time.Duration
+	doc          *Doc
+	streaming    bool
+	maxBodySize  int64
+	version      string
+	deprecation  *deprecation
+	etag         bool
+	cacheControl string
+}
+
+// deprecation 路由弃用信息
+type deprecation struct {
+	sunset    time.Time // 下线时间
+	successor string    // 替代接口地址
+}
+
+// WithTimeout 设置路由的处理时限；到期后 RequestContext 被取消，未完成的请求返回 RequestTimeout
+func WithTimeout(timeout time.Duration) RouteOption {
+	return func(opt *routeOption) {
+		opt.timeout = timeout
+	}
+}
+
+// WithDoc 设置路由的接口文档描述，用于生成 OpenAPI 文档
+func WithDoc(doc Doc) RouteOption {
+	return func(opt *routeOption) {
+		opt.doc = &doc
+	}
+}
+
+// WithStreaming 开启流式请求模式：请求体不缓存至内存，由 handler 直接读取 Request().Body，
+// Trace 仅记录读取的大小与 SHA-256；适用于安装包、导入文件等大文件上传
+func WithStreaming() RouteOption {
+	return func(opt *routeOption) {
+		opt.streaming = true
+	}
+}
+
+// WithBodyLimit 设置路由的请求体大小上限(单位字节)，覆盖全局配置；< 0 时不限制
+func WithBodyLimit(size int64) RouteOption {
+	return func(opt *routeOption) {
+		opt.maxBodySize = size
+	}
+}
+
+// WithVersion 标记路由所属的接口版本，通常通过 RouterGroup.Version 设置
+func WithVersion(version string) RouteOption {
+	return func(opt *routeOption) {
+		opt.version = version
+	}
+}
+
+// WithDeprecated 标记路由已弃用：响应附带 Deprecation、Sunset Header，successor 不为空时附带
+// Link: <successor>; rel="successor-version"，调用次数记录至指标
+func WithDeprecated(sunset time.Time, successor string) RouteOption {
+	return func(opt *routeOption) {
+		opt.deprecation = &deprecation{sunset: sunset, successor: successor}
+	}
+}
+
+// setDeprecationHeaders 设置弃用相关的 Header (RFC 8594)
+func setDeprecationHeaders(header http.Header, d *deprecation) {
+	header.Set("Deprecation", "true")
+	if !d.sunset.IsZero() {
+		header.Set("Sunset", d.sunset.UTC().Format(http.TimeFormat))
+	}
+	if d.successor != "" {
+		header.Add("Link", "<"+d.successor+`>; rel="successor-version"`)
+	}
+}
+
+// anyMethods 与 gin.RouterGroup.Any 注册的方法保持一致
+var anyMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodHead, http.MethodOptions, http.MethodDelete, http.MethodConnect,
+	http.MethodTrace,
+}
+
+// routeTable 记录已注册路由的路由级配置，以 method + gin.Context.FullPath 为索引
+type routeTable struct {
+	mux    sync.RWMutex
+	routes map[string]*routeOption
+	order  []*routeOption // 按注册顺序排列
+}
+
+func newRouteTable() *routeTable {
+	return &routeTable{routes: make(map[string]*routeOption)}
+}
+
+func (t *routeTable) add(method, fullPath string, options []RouteOption) {
+	opt := &routeOption{method: method, path: fullPath}
+	for _, f := range options {
+		f(opt)
+	}
+
+	t.mux.Lock()
+	t.routes[method+" "+fullPath] = opt
+	t.order = append(t.order, opt)
+	t.mux.Unlock()
+}
+
+// list 按注册顺序返回所有路由
+func (t *routeTable) list() []*routeOption {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+
+	routes := make([]*routeOption, len(t.order))
+	copy(routes, t.order)
+	return routes
+}
+
+// lookup 查找路由级配置，未匹配到路由时返回空配置
+func (t *routeTable) lookup(method, fullPath string) *routeOption {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+
+	if opt, ok := t.routes[method+" "+fullPath]; ok {
+		return opt
+	}
+	return new(routeOption)
+}
+
+// joinPaths 与 gin 拼接路由路径的规则保持一致
+func joinPaths(absolutePath, relativePath string) string {
+	if relativePath == "" {
+		return absolutePath
+	}
+
+	finalPath := path.Join(absolutePath, relativePath)
+	if relativePath[len(relativePath)-1] == '/' && finalPath[len(finalPath)-1] != '/' {
+		return finalPath + "/"
+	}
+	return finalPath
+}
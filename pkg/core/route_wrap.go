@@ -1,10 +1,18 @@
 package core
 
-import "github.com/gin-gonic/gin"
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
 
 // RouterGroup 包装gin的RouterGroup
 type RouterGroup interface {
 	Group(string, ...HandlerFunc) RouterGroup
+	// Version 创建接口版本分组(如 /api/v1)，options 作用于分组内的所有路由，如整个版本弃用(WithDeprecated)
+	Version(version string, options ...RouteOption) RouterGroup
+	// With 返回附加了路由级配置的 IRoutes，配置仅作用于通过其注册的路由
+	With(...RouteOption) IRoutes
 	IRoutes
 }
 
@@ -23,43 +31,75 @@ type IRoutes interface {
 }
 
 type router struct {
-	group *gin.RouterGroup
+	group   *gin.RouterGroup
+	table   *routeTable
+	options []RouteOption
 }
 
 func (r *router) Group(relativePath string, handlers ...HandlerFunc) RouterGroup {
 	group := r.group.Group(relativePath, wrapHandlers(handlers...)...)
-	return &router{group: group}
+	return &router{group: group, table: r.table, options: r.options}
+}
+
+func (r *router) Version(version string, options ...RouteOption) RouterGroup {
+	merged := make([]RouteOption, 0, len(r.options)+len(options)+1)
+	merged = append(merged, r.options...)
+	merged = append(merged, WithVersion(version))
+	merged = append(merged, options...)
+	return &router{group: r.group.Group(version), table: r.table, options: merged}
+}
+
+func (r *router) With(options ...RouteOption) IRoutes {
+	merged := make([]RouteOption, 0, len(r.options)+len(options))
+	merged = append(merged, r.options...)
+	merged = append(merged, options...)
+	return &router{group: r.group, table: r.table, options: merged}
+}
+
+func (r *router) register(relativePath string, methods ...string) {
+	fullPath := joinPaths(r.group.BasePath(), relativePath)
+	for _, method := range methods {
+		r.table.add(method, fullPath, r.options)
+	}
 }
 
 func (r *router) Any(relativePath string, handlers ...HandlerFunc) {
+	r.register(relativePath, anyMethods...)
 	r.group.Any(relativePath, wrapHandlers(handlers...)...)
 }
 
 func (r *router) GET(relativePath string, handlers ...HandlerFunc) {
+	r.register(relativePath, http.MethodGet)
 	r.group.GET(relativePath, wrapHandlers(handlers...)...)
 }
 
 func (r *router) POST(relativePath string, handlers ...HandlerFunc) {
+	r.register(relativePath, http.MethodPost)
 	r.group.POST(relativePath, wrapHandlers(handlers...)...)
 }
 
 func (r *router) DELETE(relativePath string, handlers ...HandlerFunc) {
+	r.register(relativePath, http.MethodDelete)
 	r.group.DELETE(relativePath, wrapHandlers(handlers...)...)
 }
 
 func (r *router) PATCH(relativePath string, handlers ...HandlerFunc) {
+	r.register(relativePath, http.MethodPatch)
 	r.group.PATCH(relativePath, wrapHandlers(handlers...)...)
 }
 
 func (r *router) PUT(relativePath string, handlers ...HandlerFunc) {
+	r.register(relativePath, http.MethodPut)
 	r.group.PUT(relativePath, wrapHandlers(handlers...)...)
 }
 
 func (r *router) OPTIONS(relativePath string, handlers ...HandlerFunc) {
+	r.register(relativePath, http.MethodOptions)
 	r.group.OPTIONS(relativePath, wrapHandlers(handlers...)...)
 }
 
 func (r *router) HEAD(relativePath string, handlers ...HandlerFunc) {
+	r.register(relativePath, http.MethodHead)
 	r.group.HEAD(relativePath, wrapHandlers(handlers...)...)
 }
 
@@ -0,0 +1,187 @@
+package core
+
+import (
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kisun-bit/aio_dashboard/configs"
+	"github.com/kisun-bit/aio_dashboard/pkg/env"
+	"github.com/kisun-bit/aio_dashboard/pkg/trace"
+)
+
+// CORSConfig 跨域配置，为空的字段使用默认值
+type CORSConfig struct {
+	// AllowOrigins 允许的来源，支持通配符，如 https://*.example.com、http://localhost:*，"*" 表示任意来源；
+	// 为空时 dev、fat 环境允许本机来源，uat、pro 环境不允许跨域
+	AllowOrigins     []string
+	AllowMethods     []string      // 允许的 Method，为空时允许常用 Method
+	AllowHeaders     []string      // 允许的请求 Header，为空时允许登录 Token、Range、If-None-Match 等本服务使用的 Header
+	ExposeHeaders    []string      // 允许前端读取的返回 Header，为空时开放 Trace ID、ETag、下载及弃用相关 Header
+	AllowCredentials bool          // 是否允许携带 Cookie 等凭证
+	MaxAge           time.Duration // 预检结果的缓存时长
+}
+
+// SecurityHeaders 每个返回都会附加的安全 Header，为空的字段使用默认值
+type SecurityHeaders struct {
+	ContentSecurityPolicy string        // 为空时仅允许同源资源(Swagger UI 随程序嵌入，同样满足)
+	HSTSMaxAge            time.Duration // 仅 https 请求返回 Strict-Transport-Security，<= 0 时不返回
+	FrameOptions          string        // X-Frame-Options，默认 DENY
+	ReferrerPolicy        string        // Referrer-Policy，默认 strict-origin-when-cross-origin
+}
+
+const cspDefault = "default-src 'self'; img-src 'self' data:; style-src 'self' 'unsafe-inline'; " +
+	"object-src 'none'; base-uri 'self'; frame-ancestors 'none'"
+
+// WithCORS 开启跨域，预检请求直接返回，不记录 Trace 及指标
+func WithCORS(cors CORSConfig) Option {
+	return func(opt *option) {
+		opt.cors = &cors
+	}
+}
+
+// WithSecurityHeaders 设置安全 Header，未设置时使用 SecurityHeaders 的默认值
+func WithSecurityHeaders(headers SecurityHeaders) Option {
+	return func(opt *option) {
+		opt.securityHeaders = headers
+	}
+}
+
+// corsPolicy 补全默认值后的跨域配置
+type corsPolicy struct {
+	origins       []string
+	anyOrigin     bool
+	methods       string
+	headers       string
+	exposeHeaders string
+	credentials   bool
+	maxAge        string
+}
+
+func newCORSPolicy(cors CORSConfig) *corsPolicy {
+	p := &corsPolicy{credentials: cors.AllowCredentials}
+
+	origins := cors.AllowOrigins
+	if len(origins) == 0 && (env.Active().IsDev() || env.Active().IsFat()) {
+		origins = []string{"http://localhost:*", "http://127.0.0.1:*"}
+	}
+	for _, origin := range origins {
+		origin = strings.TrimRight(strings.TrimSpace(origin), "/")
+		if origin == "*" {
+			p.anyOrigin = true
+		}
+		if origin != "" {
+			p.origins = append(p.origins, strings.ToLower(origin))
+		}
+	}
+
+	methods := cors.AllowMethods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead, http.MethodOptions}
+	}
+	p.methods = strings.ToUpper(strings.Join(methods, ", "))
+
+	headers := cors.AllowHeaders
+	if len(headers) == 0 {
+		headers = []string{"Content-Type", "Accept-Language", configs.HeaderLoginToken, configs.HeaderSignToken,
+			configs.HeaderSignTokenDate, trace.Header, "Range", "If-Range", "If-None-Match", "Content-Range", HeaderUploadOffset, HeaderIdempotencyKey}
+	}
+	p.headers = strings.Join(headers, ", ")
+
+	exposeHeaders := cors.ExposeHeaders
+	if len(exposeHeaders) == 0 {
+		exposeHeaders = []string{trace.Header, "ETag", "Content-Disposition", "Content-Range", HeaderUploadOffset,
+			"Deprecation", "Sunset", "Link", "Retry-After", HeaderIdempotentReplayed}
+	}
+	p.exposeHeaders = strings.Join(exposeHeaders, ", ")
+
+	if cors.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(int(cors.MaxAge / time.Second))
+	}
+	return p
+}
+
+func (p *corsPolicy) allowed(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+
+	origin = strings.ToLower(origin)
+	for _, pattern := range p.origins {
+		if matched, _ := path.Match(pattern, origin); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// handle 设置跨域 Header，返回 true 表示预检请求已处理完成
+func (p *corsPolicy) handle(c *gin.Context) bool {
+	origin := c.GetHeader("Origin")
+	header := c.Writer.Header()
+	header.Add("Vary", "Origin")
+
+	preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+	if origin == "" {
+		return false
+	}
+	if !p.allowed(origin) {
+		if preflight {
+			c.AbortWithStatus(http.StatusForbidden)
+		}
+		return preflight
+	}
+
+	// 允许凭证时不能返回 "*"，须回显具体来源
+	if p.anyOrigin && !p.credentials {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.credentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if !preflight {
+		header.Set("Access-Control-Expose-Headers", p.exposeHeaders)
+		return false
+	}
+
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+	header.Set("Access-Control-Allow-Methods", p.methods)
+	header.Set("Access-Control-Allow-Headers", p.headers)
+	if p.maxAge != "" {
+		header.Set("Access-Control-Max-Age", p.maxAge)
+	}
+	c.AbortWithStatus(http.StatusNoContent)
+	return true
+}
+
+// setSecurityHeaders 附加安全 Header，在 handler 之前设置，handler 可按需覆盖
+func setSecurityHeaders(c *gin.Context, headers SecurityHeaders) {
+	csp := headers.ContentSecurityPolicy
+	if csp == "" {
+		csp = cspDefault
+	}
+	frameOptions := headers.FrameOptions
+	if frameOptions == "" {
+		frameOptions = "DENY"
+	}
+	referrerPolicy := headers.ReferrerPolicy
+	if referrerPolicy == "" {
+		referrerPolicy = "strict-origin-when-cross-origin"
+	}
+
+	header := c.Writer.Header()
+	header.Set("Content-Security-Policy", csp)
+	header.Set("X-Frame-Options", frameOptions)
+	header.Set("Referrer-Policy", referrerPolicy)
+	header.Set("X-Content-Type-Options", "nosniff")
+	if c.Request.TLS != nil && headers.HSTSMaxAge > 0 {
+		header.Set("Strict-Transport-Security", "max-age="+strconv.Itoa(int(headers.HSTSMaxAge/time.Second)))
+	}
+}
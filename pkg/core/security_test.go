@@ -0,0 +1,133 @@
+package core
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestCORSPolicyAllowed(t *testing.T) {
+	tests := []struct {
+		name    string
+		origins []string
+		origin  string
+		want    bool
+	}{
+		{"exact", []string{"https://dashboard.example.com"}, "https://dashboard.example.com", true},
+		{"case insensitive", []string{"https://Dashboard.example.com/"}, "https://DASHBOARD.example.com", true},
+		{"subdomain wildcard", []string{"https://*.example.com"}, "https://a.example.com", true},
+		{"nested subdomain", []string{"https://*.example.com"}, "https://a.b.example.com", true},
+		{"wildcard needs subdomain", []string{"https://*.example.com"}, "https://example.com", false},
+		{"suffix attack", []string{"https://*.example.com"}, "https://a.example.com.evil.com", false},
+		{"scheme differs", []string{"https://dashboard.example.com"}, "http://dashboard.example.com", false},
+		{"port wildcard", []string{"http://localhost:*"}, "http://localhost:5173", true},
+		{"any", []string{"*"}, "https://anything.test", true},
+		{"none", []string{""}, "https://anything.test", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newCORSPolicy(CORSConfig{AllowOrigins: tt.origins})
+			if got := p.allowed(tt.origin); got != tt.want {
+				t.Errorf("allowed(%q) = %v, want %v", tt.origin, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCORSHandle(t *testing.T) {
+	mux, err := New(zap.NewNop(),
+		WithCORS(CORSConfig{AllowOrigins: []string{"https://*.example.com"}, AllowCredentials: true, MaxAge: 10 * time.Minute}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.Group("/api").GET("/ping", func(ctx ContextWrap) { ctx.Payload("pong") })
+
+	preflight := http.Header{"Access-Control-Request-Method": {http.MethodGet}}
+
+	tests := []struct {
+		name        string
+		method      string
+		header      http.Header
+		wantCode    int
+		wantOrigin  string
+		wantMaxAge  string
+		wantExposed bool
+	}{
+		{"same origin", http.MethodGet, nil, http.StatusOK, "", "", false},
+		{"allowed request", http.MethodGet, http.Header{"Origin": {"https://a.example.com"}}, http.StatusOK, "https://a.example.com", "", true},
+		{"denied request", http.MethodGet, http.Header{"Origin": {"https://evil.com"}}, http.StatusOK, "", "", false},
+		{"allowed preflight", http.MethodOptions, merge(preflight, http.Header{"Origin": {"https://a.example.com"}}), http.StatusNoContent, "https://a.example.com", "600", false},
+		{"denied preflight", http.MethodOptions, merge(preflight, http.Header{"Origin": {"https://evil.com"}}), http.StatusForbidden, "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(mux, tt.method, "/api/ping", tt.header)
+			if w.Code != tt.wantCode {
+				t.Fatalf("code = %d, want %d", w.Code, tt.wantCode)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if got := w.Header().Get("Access-Control-Max-Age"); got != tt.wantMaxAge {
+				t.Errorf("Max-Age = %q, want %q", got, tt.wantMaxAge)
+			}
+			if got := w.Header().Get("Access-Control-Expose-Headers") != ""; got != tt.wantExposed {
+				t.Errorf("Expose-Headers present = %v", got)
+			}
+			if tt.wantOrigin != "" && w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+				t.Error("Allow-Credentials missing")
+			}
+		})
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	mux, err := New(zap.NewNop(), WithSecurityHeaders(SecurityHeaders{HSTSMaxAge: time.Hour, FrameOptions: "SAMEORIGIN"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.Group("/api").GET("/ping", func(ctx ContextWrap) { ctx.Payload("pong") })
+
+	for _, path := range []string{"/api/ping", "/missing"} {
+		w := serve(mux, http.MethodGet, path, nil)
+		if w.Header().Get("Content-Security-Policy") != cspDefault ||
+			w.Header().Get("X-Frame-Options") != "SAMEORIGIN" ||
+			w.Header().Get("X-Content-Type-Options") != "nosniff" ||
+			w.Header().Get("Referrer-Policy") != "strict-origin-when-cross-origin" {
+			t.Errorf("%s security headers = %v", path, w.Header())
+		}
+		if w.Header().Get("Strict-Transport-Security") != "" {
+			t.Errorf("%s HSTS over plain http", path)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "https://dashboard/api/ping", nil)
+	req.TLS = &tls.ConnectionState{}
+	w := serveRequest(mux, req)
+	if got := w.Header().Get("Strict-Transport-Security"); got != "max-age=3600" {
+		t.Errorf("HSTS = %q", got)
+	}
+}
+
+func merge(headers ...http.Header) http.Header {
+	merged := http.Header{}
+	for _, h := range headers {
+		for k, v := range h {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+func serveRequest(mux http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	return w
+}
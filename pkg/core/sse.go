@@ -0,0 +1,204 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	_SSEName = "_sse_"
+
+	// _HeaderLastEventID 客户端重连时携带的最后一次收到的事件ID
+	_HeaderLastEventID = "Last-Event-ID"
+
+	defaultSSEHeartbeat = 15 * time.Second
+)
+
+// ErrSSEClosed 推送流已结束(客户端断开或回调已返回)
+var ErrSSEClosed = errors.New("sse stream closed")
+
+// SSEvent Server-Sent Events 事件
+type SSEvent struct {
+	ID    string      // 事件ID，客户端重连时通过 Last-Event-ID 带回
+	Event string      // 事件类型，为空时客户端按 message 处理
+	Data  interface{} // 事件数据，struct / slice / map 以 JSON 编码
+}
+
+// SSEStream SSE 推送流，仅在 ContextWrap.SSE 的回调中有效
+type SSEStream interface {
+	// LastEventID 客户端重连时携带的 Last-Event-ID，首次连接时为空，用于断点续推
+	LastEventID() string
+
+	// Send 推送事件，客户端断开后返回错误
+	Send(event SSEvent) error
+
+	// Done 客户端断开、请求超时或写入失败时关闭
+	Done() <-chan struct{}
+}
+
+// SSEOption SSE 推送配置
+type SSEOption func(*sseOption)
+
+type sseOption struct {
+	heartbeat time.Duration
+	retry     time.Duration
+}
+
+// WithSSEHeartbeat 设置心跳间隔，期间没有事件时发送注释行，避免被代理断开；默认 15 秒，<= 0 时关闭心跳
+func WithSSEHeartbeat(interval time.Duration) SSEOption {
+	return func(opt *sseOption) {
+		opt.heartbeat = interval
+	}
+}
+
+// WithSSERetry 设置客户端断线后的重连间隔
+func WithSSERetry(retry time.Duration) SSEOption {
+	return func(opt *sseOption) {
+		opt.retry = retry
+	}
+}
+
+// sseSummary 推送结束后记录至 Trace 的返回信息
+type sseSummary struct {
+	Events       int    `json:"events"`        // 推送的事件数
+	LastEventID  string `json:"last_event_id"` // 最后推送的事件ID
+	Disconnected bool   `json:"disconnected"`  // 是否因客户端断开而结束
+}
+
+type sseStream struct {
+	mux         sync.Mutex
+	writer      gin.ResponseWriter
+	lastEventID string
+	done        chan struct{}
+	closeOnce   sync.Once
+	summary     sseSummary
+}
+
+func (s *sseStream) LastEventID() string {
+	return s.lastEventID
+}
+
+func (s *sseStream) Done() <-chan struct{} {
+	return s.done
+}
+
+func (s *sseStream) Send(event SSEvent) error {
+	buf := new(bytes.Buffer)
+	if err := sse.Encode(buf, sse.Event{Id: event.ID, Event: event.Event, Data: event.Data}); err != nil {
+		return err
+	}
+
+	if err := s.write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	s.mux.Lock()
+	s.summary.Events++
+	if event.ID != "" {
+		s.summary.LastEventID = event.ID
+	}
+	s.mux.Unlock()
+	return nil
+}
+
+// write 写入并立即 Flush；写入失败视为客户端断开
+func (s *sseStream) write(data []byte) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	select {
+	case <-s.done:
+		return ErrSSEClosed
+	default:
+	}
+
+	if _, err := s.writer.Write(data); err != nil {
+		s.close(true)
+		return err
+	}
+	s.writer.Flush()
+	return nil
+}
+
+func (s *sseStream) close(disconnected bool) {
+	s.closeOnce.Do(func() {
+		s.summary.Disconnected = disconnected
+		close(s.done)
+	})
+}
+
+// SSE 以 Server-Sent Events 推送数据，handle 返回后结束推送
+func (c *GinContext) SSE(handle func(stream SSEStream), options ...SSEOption) {
+	opt := &sseOption{heartbeat: defaultSSEHeartbeat}
+	for _, f := range options {
+		f(opt)
+	}
+
+	header := c.ctx.Writer.Header()
+	header.Set("Content-Type", sse.ContentType)
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	c.ctx.Status(http.StatusOK)
+
+	stream := &sseStream{
+		writer:      c.ctx.Writer,
+		lastEventID: c.ctx.GetHeader(_HeaderLastEventID),
+		done:        make(chan struct{}),
+	}
+	c.ctx.Set(_SSEName, &stream.summary)
+
+	// 首行注释携带链路ID，便于浏览器端(无法读取响应 Header)排查
+	opening := ": trace_id=" + traceID(c) + "\n"
+	if opt.retry > 0 {
+		opening += "retry:" + strconv.FormatInt(opt.retry.Milliseconds(), 10) + "\n"
+	}
+	if err := stream.write([]byte(opening + "\n")); err != nil {
+		return
+	}
+
+	requestDone := c.ctx.Request.Context().Done()
+	finished := make(chan struct{})
+	defer func() {
+		close(finished)
+
+		// 等待进行中的写入完成，回调返回后不再写入
+		stream.mux.Lock()
+		stream.close(false)
+		stream.mux.Unlock()
+	}()
+
+	go func() {
+		var tick <-chan time.Time
+		if opt.heartbeat > 0 {
+			ticker := time.NewTicker(opt.heartbeat)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		for {
+			select {
+			case <-finished:
+				return
+			case <-stream.done:
+				return
+			case <-requestDone:
+				stream.mux.Lock()
+				stream.close(true)
+				stream.mux.Unlock()
+				return
+			case <-tick:
+				_ = stream.write([]byte(": ping\n\n"))
+			}
+		}
+	}()
+
+	handle(stream)
+}
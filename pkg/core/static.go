@@ -0,0 +1,204 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	ginrender "github.com/gin-gonic/gin/render"
+)
+
+// staticFS 前端静态资源，按文件内容计算哈希用于 ETag 及 asset 模板函数
+type staticFS struct {
+	fsys   fs.FS
+	dev    bool              // dev 环境不缓存，每次请求直接读取
+	hashes map[string]string // 文件路径 -> 内容哈希
+}
+
+func newStaticFS(fsys fs.FS, dev bool) (*staticFS, error) {
+	s := &staticFS{
+		fsys:   fsys,
+		dev:    dev,
+		hashes: make(map[string]string),
+	}
+	if dev {
+		return s, nil
+	}
+
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		f, err := fsys.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		h := sha256.New()
+		if _, err = io.Copy(h, f); err != nil {
+			return err
+		}
+		s.hashes[name] = hex.EncodeToString(h.Sum(nil))[:16]
+		return nil
+	})
+	return s, err
+}
+
+// handle 作为 NoRoute 处理未注册路由的 GET/HEAD 请求：
+// 优先返回静态文件；无扩展名且接受 text/html 的请求回退至 index.html，以支持 SPA history 路由
+func (s *staticFS) handle(c *gin.Context) {
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		return
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+c.Request.URL.Path), "/")
+	if name == "" {
+		name = "index.html"
+	}
+	if s.serve(c, name) {
+		return
+	}
+
+	if path.Ext(name) == "" && strings.Contains(c.GetHeader("Accept"), "text/html") {
+		s.serve(c, "index.html")
+	}
+}
+
+func (s *staticFS) serve(c *gin.Context, name string) bool {
+	f, err := s.fsys.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil || stat.IsDir() {
+		return false
+	}
+
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		return false
+	}
+
+	switch {
+	case s.dev:
+		c.Header("Cache-Control", "no-store")
+	case isHashedName(name):
+		c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	default:
+		c.Header("Cache-Control", "no-cache")
+	}
+	if hash, ok := s.hashes[name]; ok {
+		c.Header("ETag", `"`+hash+`"`)
+	}
+
+	// 静态文件不经过路由，以独立的指标标签与真正的 404 区分
+	c.Set(_StaticName, true)
+
+	// ServeContent 会根据 ETag 处理 If-None-Match 并返回 304
+	http.ServeContent(c.Writer, c.Request, name, stat.ModTime(), content)
+	return true
+}
+
+// asset 模板函数，为静态文件地址附加内容哈希，如 {{ asset "logo.png" }} -> /logo.png?v=3f2a1b9c0d4e5f6a
+func (s *staticFS) asset(name string) string {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if hash, ok := s.hashes[name]; ok {
+		return "/" + name + "?v=" + hash
+	}
+	return "/" + name
+}
+
+// isHashedName 判断是否为构建工具输出的带内容哈希的文件名，如 index-4f3a9b1c.js、app.3f2a1b9c.css，
+// 此类文件内容变化时文件名随之变化，可长期缓存
+func isHashedName(name string) bool {
+	base := path.Base(name)
+	base = strings.TrimSuffix(base, path.Ext(base))
+
+	i := strings.LastIndexAny(base, ".-")
+	if i < 0 {
+		return false
+	}
+
+	hash := base[i+1:]
+	if len(hash) < 8 {
+		return false
+	}
+
+	var hasDigit bool
+	for _, r := range hash {
+		switch {
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case r == '_' || r < unicode.MaxASCII && unicode.IsLetter(r):
+		default:
+			return false
+		}
+	}
+	return hasDigit
+}
+
+var _ ginrender.HTMLRender = (*htmlRender)(nil)
+
+// htmlRender 从 fs.FS 加载模板；dev 环境每次渲染时重新解析，以便实时修改
+type htmlRender struct {
+	fsys     fs.FS
+	patterns []string
+	funcs    template.FuncMap
+	dev      bool
+	tmpl     *template.Template
+}
+
+func newHTMLRender(fsys fs.FS, patterns []string, funcs template.FuncMap, dev bool) (*htmlRender, error) {
+	r := &htmlRender{
+		fsys:     fsys,
+		patterns: patterns,
+		funcs:    funcs,
+		dev:      dev,
+	}
+
+	tmpl, err := r.parse()
+	if err != nil {
+		return nil, err
+	}
+	r.tmpl = tmpl
+
+	return r, nil
+}
+
+func (r *htmlRender) parse() (*template.Template, error) {
+	return template.New("").Funcs(r.funcs).ParseFS(r.fsys, r.patterns...)
+}
+
+func (r *htmlRender) Instance(name string, data interface{}) ginrender.Render {
+	tmpl := r.tmpl
+	if r.dev {
+		tmpl = template.Must(r.parse())
+	}
+
+	return ginrender.HTML{
+		Template: tmpl,
+		Name:     name,
+		Data:     data,
+	}
+}
+
+// templateFuncs 模板公共函数
+func templateFuncs(static *staticFS) template.FuncMap {
+	if static == nil {
+		static = &staticFS{hashes: map[string]string{}}
+	}
+	return template.FuncMap{
+		"asset": static.asset,
+	}
+}
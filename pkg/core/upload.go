@@ -0,0 +1,299 @@
+package core
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kisun-bit/aio_dashboard/internal/code"
+)
+
+const (
+	// HeaderUploadOffset 分片上传的起始偏移；也可使用 Content-Range: bytes start-end/total
+	HeaderUploadOffset = "Upload-Offset"
+
+	uploadMetaExt = ".json"
+	uploadPartExt = ".part"
+)
+
+var uploadIDRegexp = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// UploadStatus 分片上传状态，客户端中断后根据 Offset 继续上传
+type UploadStatus struct {
+	ID        string    `json:"id"`         // 上传ID
+	Name      string    `json:"name"`       // 文件名
+	Size      int64     `json:"size"`       // 文件总大小
+	Offset    int64     `json:"offset"`     // 已接收的字节数
+	Completed bool      `json:"completed"`  // 是否已接收完整
+	CreatedAt time.Time `json:"created_at"` // 创建时间
+}
+
+// UploadStore 以上传ID为索引在 dir 中保存分片上传的数据，同一上传ID的分片串行写入
+type UploadStore struct {
+	dir string
+
+	mux   sync.Mutex
+	locks map[string]*uploadLock
+}
+
+// uploadLock 上传ID的互斥锁，refs 为持有及等待的数量，归零时才从 locks 中移除，
+// 保证同一上传ID的操作始终使用同一把锁
+type uploadLock struct {
+	sync.Mutex
+	refs int
+}
+
+// NewUploadStore 创建分片上传存储，dir 不存在时自动创建
+func NewUploadStore(dir string) (*UploadStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &UploadStore{dir: dir, locks: make(map[string]*uploadLock)}, nil
+}
+
+// Create 创建上传任务，返回的 ID 用于后续上传分片及查询进度
+func (s *UploadStore) Create(name string, size int64) (*UploadStatus, error) {
+	if size < 0 {
+		return nil, errors.New("upload size must not be negative")
+	}
+
+	buf := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+		return nil, err
+	}
+
+	status := &UploadStatus{
+		ID:        hex.EncodeToString(buf),
+		Name:      filepath.Base(name),
+		Size:      size,
+		CreatedAt: time.Now(),
+	}
+
+	meta, _ := json.Marshal(status)
+	if err := ioutil.WriteFile(s.path(status.ID, uploadMetaExt), meta, 0600); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(s.path(status.ID, uploadPartExt), nil, 0600); err != nil {
+		return nil, err
+	}
+	status.Completed = size == 0
+	return status, nil
+}
+
+// Status 查询上传进度，上传ID不存在时返回 os.ErrNotExist
+func (s *UploadStore) Status(id string) (*UploadStatus, error) {
+	if !uploadIDRegexp.MatchString(id) {
+		return nil, os.ErrNotExist
+	}
+
+	meta, err := ioutil.ReadFile(s.path(id, uploadMetaExt))
+	if err != nil {
+		return nil, err
+	}
+
+	status := new(UploadStatus)
+	if err = json.Unmarshal(meta, status); err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(s.path(id, uploadPartExt))
+	if err != nil {
+		return nil, err
+	}
+	status.Offset = info.Size()
+	status.Completed = status.Offset == status.Size
+	return status, nil
+}
+
+// Path 已接收数据的文件路径，上传完成后由调用方读取或移动
+func (s *UploadStore) Path(id string) string {
+	return s.path(id, uploadPartExt)
+}
+
+// Remove 删除上传任务及已接收的数据
+func (s *UploadStore) Remove(id string) error {
+	if !uploadIDRegexp.MatchString(id) {
+		return os.ErrNotExist
+	}
+
+	s.lock(id)
+	defer s.unlock(id)
+
+	return s.remove(id)
+}
+
+// Clean 删除超过 expiration 未接收新数据的上传任务(按已接收数据文件的修改时间)，返回删除的数量；
+// 正在上传的任务持续更新修改时间，不会被删除
+func (s *UploadStore) Clean(expiration time.Duration) (int, error) {
+	entries, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	deadline := time.Now().Add(-expiration)
+	for _, entry := range entries {
+		id := strings.TrimSuffix(entry.Name(), uploadMetaExt)
+		if id == entry.Name() || !uploadIDRegexp.MatchString(id) {
+			continue
+		}
+
+		if s.removeExpired(id, entry.ModTime(), deadline) {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// removeExpired 持有上传ID的锁检查并删除过期的上传任务；数据文件缺失时按元数据文件的修改时间 metaModTime 判断
+func (s *UploadStore) removeExpired(id string, metaModTime, deadline time.Time) bool {
+	s.lock(id)
+	defer s.unlock(id)
+
+	modTime := metaModTime
+	if info, err := os.Stat(s.path(id, uploadPartExt)); err == nil {
+		modTime = info.ModTime()
+	}
+	if !modTime.Before(deadline) {
+		return false
+	}
+	return s.remove(id) == nil
+}
+
+// remove 删除上传任务的数据文件及元数据文件，调用方需持有上传ID的锁
+func (s *UploadStore) remove(id string) error {
+	if err := os.Remove(s.path(id, uploadPartExt)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Remove(s.path(id, uploadMetaExt))
+}
+
+// append 在 offset 处追加 body 中的 length 字节；offset 与已接收的字节数不一致时返回 errUploadOffset
+func (s *UploadStore) append(id string, offset, length int64, body io.Reader) (*UploadStatus, error) {
+	s.lock(id)
+	defer s.unlock(id)
+
+	status, err := s.Status(id)
+	if err != nil {
+		return nil, err
+	}
+	if offset != status.Offset || offset+length > status.Size {
+		return status, errUploadOffset
+	}
+
+	f, err := os.OpenFile(s.path(id, uploadPartExt), os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return status, err
+	}
+	defer f.Close()
+
+	n, err := io.Copy(f, io.LimitReader(body, length))
+	if err == nil && n != length {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		// 丢弃不完整的分片，客户端从原偏移重传
+		_ = f.Truncate(offset)
+		return status, err
+	}
+
+	status.Offset += n
+	status.Completed = status.Offset == status.Size
+	return status, nil
+}
+
+// lock 获取上传ID的锁，需与 unlock 成对调用
+func (s *UploadStore) lock(id string) {
+	s.mux.Lock()
+	l := s.locks[id]
+	if l == nil {
+		l = new(uploadLock)
+		s.locks[id] = l
+	}
+	l.refs++
+	s.mux.Unlock()
+
+	l.Lock()
+}
+
+// unlock 释放上传ID的锁，没有其他持有或等待者时移除
+func (s *UploadStore) unlock(id string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	l := s.locks[id]
+	l.Unlock()
+	if l.refs--; l.refs == 0 {
+		delete(s.locks, id)
+	}
+}
+
+func (s *UploadStore) path(id, ext string) string {
+	return filepath.Join(s.dir, id+ext)
+}
+
+var errUploadOffset = errors.New("upload offset mismatch")
+
+// UploadChunk 接收上传ID为 uploadID 的一个分片，建议与 WithStreaming 一同使用；
+// 分片起始偏移取自 Content-Range(bytes start-end/total) 或 Upload-Offset，长度取自 Content-Length；
+// 偏移与已接收的字节数不一致时返回 UploadOffsetMismatch，当前进度作为返回结构中的 data
+func (c *GinContext) UploadChunk(store *UploadStore, uploadID string) (*UploadStatus, BusinessError) {
+	offset, length, err := parseChunkRange(c.ctx.GetHeader("Content-Range"), c.ctx.GetHeader(HeaderUploadOffset), c.ctx.Request.ContentLength)
+	if err != nil {
+		return nil, ParamBindError(err)
+	}
+
+	status, err := store.append(uploadID, offset, length, c.ctx.Request.Body)
+	if status != nil {
+		c.ctx.Header(HeaderUploadOffset, strconv.FormatInt(status.Offset, 10))
+	}
+
+	switch {
+	case err == nil:
+		return status, nil
+	case os.IsNotExist(err):
+		return nil, Code(code.UploadNotFound).WithError(err)
+	case errors.Is(err, errUploadOffset):
+		return nil, Code(code.UploadOffsetMismatch).WithError(err).WithDetails(status)
+	default:
+		if _, ok := isBodyTooLarge(err); ok {
+			return nil, ParamBindError(err)
+		}
+		return nil, Code(code.ServerError).WithError(err).WithDetails(status)
+	}
+}
+
+// parseChunkRange 解析分片的起始偏移与长度
+func parseChunkRange(contentRange, uploadOffset string, contentLength int64) (offset, length int64, err error) {
+	if contentRange != "" {
+		var start, end int64
+		var total string
+		if _, err = fmt.Sscanf(contentRange, "bytes %d-%d/%s", &start, &end, &total); err != nil || end < start {
+			return 0, 0, fmt.Errorf("invalid Content-Range %q", contentRange)
+		}
+		length = end - start + 1
+		if contentLength >= 0 && contentLength != length {
+			return 0, 0, fmt.Errorf("Content-Range %q does not match Content-Length %d", contentRange, contentLength)
+		}
+		return start, length, nil
+	}
+
+	if offset, err = strconv.ParseInt(uploadOffset, 10, 64); err != nil || offset < 0 {
+		return 0, 0, fmt.Errorf("invalid %s %q", HeaderUploadOffset, uploadOffset)
+	}
+	if contentLength < 0 {
+		return 0, 0, errors.New("Content-Length required")
+	}
+	return offset, contentLength, nil
+}
@@ -0,0 +1,250 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/kisun-bit/aio_dashboard/internal/code"
+	"go.uber.org/zap"
+)
+
+func TestParseChunkRange(t *testing.T) {
+	tests := []struct {
+		name          string
+		contentRange  string
+		uploadOffset  string
+		contentLength int64
+		wantOffset    int64
+		wantLength    int64
+		wantErr       bool
+	}{
+		{name: "content range", contentRange: "bytes 0-99/1000", contentLength: 100, wantOffset: 0, wantLength: 100},
+		{name: "content range unknown total", contentRange: "bytes 100-199/*", contentLength: 100, wantOffset: 100, wantLength: 100},
+		{name: "content range without length", contentRange: "bytes 10-19/20", contentLength: -1, wantOffset: 10, wantLength: 10},
+		{name: "content range preferred", contentRange: "bytes 5-9/10", uploadOffset: "0", contentLength: 5, wantOffset: 5, wantLength: 5},
+		{name: "content range length mismatch", contentRange: "bytes 0-99/1000", contentLength: 50, wantErr: true},
+		{name: "content range reversed", contentRange: "bytes 9-5/10", contentLength: -1, wantErr: true},
+		{name: "content range malformed", contentRange: "items 0-9/10", contentLength: 10, wantErr: true},
+		{name: "upload offset", uploadOffset: "2048", contentLength: 512, wantOffset: 2048, wantLength: 512},
+		{name: "upload offset empty body", uploadOffset: "0", contentLength: 0, wantOffset: 0, wantLength: 0},
+		{name: "upload offset negative", uploadOffset: "-1", contentLength: 10, wantErr: true},
+		{name: "upload offset invalid", uploadOffset: "abc", contentLength: 10, wantErr: true},
+		{name: "upload offset without length", uploadOffset: "0", contentLength: -1, wantErr: true},
+		{name: "no offset", contentLength: 10, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offset, length, err := parseChunkRange(tt.contentRange, tt.uploadOffset, tt.contentLength)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (offset != tt.wantOffset || length != tt.wantLength) {
+				t.Errorf("got %d+%d, want %d+%d", offset, length, tt.wantOffset, tt.wantLength)
+			}
+		})
+	}
+}
+
+func newUploadTestStore(t *testing.T, size int64) (*UploadStore, *UploadStatus) {
+	t.Helper()
+
+	store, err := NewUploadStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := store.Create("../backup.img", size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Name != "backup.img" {
+		t.Fatalf("Name = %q", status.Name)
+	}
+	return store, status
+}
+
+func TestUploadChunk(t *testing.T) {
+	store, status := newUploadTestStore(t, 10)
+
+	mux, err := New(zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.Group("/api").PUT("/upload", func(ctx ContextWrap) {
+		uploaded, err := ctx.UploadChunk(store, ctx.Request().URL.Query().Get("id"))
+		if err != nil {
+			ctx.AbortWithError(err)
+			return
+		}
+		ctx.Payload(uploaded)
+	})
+
+	tests := []struct {
+		name       string
+		id         string
+		header     http.Header
+		body       string
+		wantCode   int
+		wantBiz    int
+		wantOffset string
+	}{
+		{name: "first chunk", header: http.Header{HeaderUploadOffset: {"0"}}, body: "0123", wantCode: http.StatusOK, wantBiz: code.OK, wantOffset: "4"},
+		{name: "replayed chunk", header: http.Header{HeaderUploadOffset: {"0"}}, body: "0123", wantCode: http.StatusConflict, wantBiz: code.UploadOffsetMismatch, wantOffset: "4"},
+		{name: "gap", header: http.Header{"Content-Range": {"bytes 6-7/10"}}, body: "67", wantCode: http.StatusConflict, wantBiz: code.UploadOffsetMismatch, wantOffset: "4"},
+		{name: "beyond size", header: http.Header{HeaderUploadOffset: {"4"}}, body: "4567890", wantCode: http.StatusConflict, wantBiz: code.UploadOffsetMismatch, wantOffset: "4"},
+		{name: "invalid range", header: http.Header{"Content-Range": {"bytes 4-9/10"}}, body: "45", wantCode: http.StatusBadRequest, wantBiz: code.ParamBindError},
+		{name: "unknown id", id: strings.Repeat("0", 32), header: http.Header{HeaderUploadOffset: {"0"}}, body: "0", wantCode: http.StatusNotFound, wantBiz: code.UploadNotFound},
+		{name: "last chunk", header: http.Header{"Content-Range": {"bytes 4-9/10"}}, body: "456789", wantCode: http.StatusOK, wantBiz: code.OK, wantOffset: "10"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := tt.id
+			if id == "" {
+				id = status.ID
+			}
+			req := httptest.NewRequest(http.MethodPut, "/api/upload?id="+id, strings.NewReader(tt.body))
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+
+			resp := new(Response)
+			if err := json.Unmarshal(w.Body.Bytes(), resp); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != tt.wantCode || resp.Code != tt.wantBiz {
+				t.Fatalf("got %d/%d, want %d/%d: %s", w.Code, resp.Code, tt.wantCode, tt.wantBiz, w.Body.String())
+			}
+			if got := w.Header().Get(HeaderUploadOffset); got != tt.wantOffset {
+				t.Errorf("%s = %q, want %q", HeaderUploadOffset, got, tt.wantOffset)
+			}
+		})
+	}
+
+	data, err := ioutil.ReadFile(store.Path(status.ID))
+	if err != nil || string(data) != "0123456789" {
+		t.Fatalf("uploaded %q, %v", data, err)
+	}
+	if final, _ := store.Status(status.ID); !final.Completed {
+		t.Errorf("status = %+v, want completed", final)
+	}
+}
+
+func TestUploadAppendTruncatesShortWrite(t *testing.T) {
+	tests := []struct {
+		name string
+		body io.Reader
+	}{
+		{name: "body ends early", body: strings.NewReader("45")},
+		{name: "body fails", body: io.MultiReader(strings.NewReader("45"), iotest.ErrReader(errors.New("connection reset")))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, status := newUploadTestStore(t, 10)
+			if _, err := store.append(status.ID, 0, 4, strings.NewReader("0123")); err != nil {
+				t.Fatal(err)
+			}
+
+			uploaded, err := store.append(status.ID, 4, 6, tt.body)
+			if err == nil {
+				t.Fatal("want error for a short chunk")
+			}
+			if uploaded.Offset != 4 {
+				t.Errorf("Offset = %d, want 4", uploaded.Offset)
+			}
+			if current, _ := store.Status(status.ID); current.Offset != 4 {
+				t.Errorf("part file holds %d bytes, want the incomplete chunk discarded", current.Offset)
+			}
+
+			if _, err = store.append(status.ID, 4, 6, strings.NewReader("456789")); err != nil {
+				t.Fatalf("retry from the same offset: %v", err)
+			}
+		})
+	}
+}
+
+func TestUploadRemoveSerialized(t *testing.T) {
+	store, status := newUploadTestStore(t, 1<<20)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, _ = store.append(status.ID, 0, 1, strings.NewReader("x"))
+		}()
+		go func() {
+			defer wg.Done()
+			_ = store.Remove(status.ID)
+		}()
+	}
+	wg.Wait()
+
+	if _, err := store.Status(status.ID); !os.IsNotExist(err) {
+		t.Errorf("Status() after Remove = %v", err)
+	}
+	if _, err := os.Stat(store.Path(status.ID)); !os.IsNotExist(err) {
+		t.Errorf("part file left behind: %v", err)
+	}
+	if len(store.locks) != 0 {
+		t.Errorf("%d locks left", len(store.locks))
+	}
+}
+
+func TestUploadClean(t *testing.T) {
+	store, err := NewUploadStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old := time.Now().Add(-2 * time.Hour)
+	create := func(partModTime time.Time) *UploadStatus {
+		status, err := store.Create("data.bin", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, ext := range []string{uploadMetaExt, uploadPartExt} {
+			if err = os.Chtimes(store.path(status.ID, ext), old, old); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err = os.Chtimes(store.Path(status.ID), partModTime, partModTime); err != nil {
+			t.Fatal(err)
+		}
+		return status
+	}
+
+	idle := create(old)
+	active := create(time.Now())
+	broken := create(old)
+	if err = os.Remove(store.Path(broken.ID)); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := store.Clean(time.Hour)
+	if err != nil || removed != 2 {
+		t.Fatalf("Clean() = %d, %v, want 2", removed, err)
+	}
+	if _, err = store.Status(idle.ID); !os.IsNotExist(err) {
+		t.Errorf("idle upload kept: %v", err)
+	}
+	if _, err = store.Status(active.ID); err != nil {
+		t.Errorf("active upload created long ago was removed: %v", err)
+	}
+	if _, err = os.Stat(store.path(broken.ID, uploadMetaExt)); !os.IsNotExist(err) {
+		t.Errorf("upload without part file kept: %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/zh"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	zhTranslations "github.com/go-playground/validator/v10/translations/zh"
+	"github.com/kisun-bit/aio_dashboard/configs"
+	"github.com/kisun-bit/aio_dashboard/internal/code"
+)
+
+// FieldError 参数校验失败的字段
+type FieldError struct {
+	Field   string `json:"field"`   // 字段名，取自 json / form / uri tag
+	Message string `json:"message"` // 本地化的错误描述
+}
+
+// FieldErrors 参数校验错误，由 ShouldBind* 按请求语言翻译生成
+type FieldErrors []FieldError
+
+func (e FieldErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, fe := range e {
+		messages[i] = fe.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// ParamBindError 将 ShouldBind* 返回的错误转换为参数错误，校验失败的字段明细作为返回结构中的 data；
+// 请求体超出大小限制时转换为 RequestEntityTooLarge
+func ParamBindError(err error) BusinessError {
+	if limit, ok := isBodyTooLarge(err); ok {
+		return bodyTooLarge(limit).WithError(err)
+	}
+
+	businessErr := Code(code.ParamBindError).WithError(err)
+
+	var fieldErrs FieldErrors
+	if errors.As(err, &fieldErrs) {
+		businessErr = businessErr.WithDetails(fieldErrs)
+	}
+	return businessErr
+}
+
+// translators 语言 -> 校验错误翻译器
+var translators = make(map[string]ut.Translator)
+
+func init() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+
+	// 错误描述中使用 tag 中的字段名，与请求参数保持一致
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, key := range []string{"json", "form", "uri"} {
+			if name := strings.Split(field.Tag.Get(key), ",")[0]; name != "" && name != "-" {
+				return name
+			}
+		}
+		return field.Name
+	})
+
+	zhLocale, enLocale := zh.New(), en.New()
+	uni := ut.New(zhLocale, zhLocale, enLocale)
+
+	zhTrans, _ := uni.GetTranslator(zhLocale.Locale())
+	if err := zhTranslations.RegisterDefaultTranslations(v, zhTrans); err == nil {
+		translators[configs.ZhCN] = zhTrans
+	}
+
+	enTrans, _ := uni.GetTranslator(enLocale.Locale())
+	if err := enTranslations.RegisterDefaultTranslations(v, enTrans); err == nil {
+		translators[configs.EnUS] = enTrans
+	}
+}
+
+// translateBindError 将 validator 的校验错误翻译为 lang 语言的 FieldErrors，其余错误原样返回
+func translateBindError(err error, lang string) error {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return err
+	}
+
+	trans, ok := translators[lang]
+	if !ok {
+		trans = translators[configs.ZhCN]
+	}
+
+	fieldErrs := make(FieldErrors, 0, len(validationErrs))
+	for _, fe := range validationErrs {
+		message := fe.Error()
+		if trans != nil {
+			message = fe.Translate(trans)
+		}
+		fieldErrs = append(fieldErrs, FieldError{Field: fe.Field(), Message: message})
+	}
+	return fieldErrs
+}
+
+// parseAcceptLanguage 按权重从 Accept-Language 中选出支持的语言，不支持时返回空
+func parseAcceptLanguage(header string) string {
+	type candidate struct {
+		lang string
+		q    float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if tag == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			if v := strings.TrimSpace(param); strings.HasPrefix(v, "q=") {
+				if parsed, err := strconv.ParseFloat(v[2:], 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+
+		if lang := normalizeLanguage(tag); lang != "" && q > 0 {
+			candidates = append(candidates, candidate{lang: lang, q: q})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].q > candidates[j].q
+	})
+	if len(candidates) == 0 {
+		return ""
+	}
+	return candidates[0].lang
+}
+
+// normalizeLanguage 将语言标签(如 zh、zh-CN、en_US)转换为 configs.ZhCN / configs.EnUS
+func normalizeLanguage(tag string) string {
+	tag = strings.ToLower(strings.ReplaceAll(tag, "_", "-"))
+	switch strings.Split(tag, "-")[0] {
+	case "zh":
+		return configs.ZhCN
+	case "en":
+		return configs.EnUS
+	default:
+		return ""
+	}
+}
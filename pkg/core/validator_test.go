@@ -0,0 +1,100 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kisun-bit/aio_dashboard/configs"
+	"go.uber.org/zap"
+)
+
+func TestParseAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"fr-FR", ""},
+		{"zh-CN", configs.ZhCN},
+		{"en_US", configs.EnUS},
+		{"en-US,en;q=0.9,zh-CN;q=0.8", configs.EnUS},
+		{"zh;q=0.5, en;q=0.8", configs.EnUS},
+		{"fr, en;q=0.3, zh;q=0.7", configs.ZhCN},
+		{"en;q=0, zh;q=0.1", configs.ZhCN},
+		{"en;q=bad, zh;q=0.9", configs.EnUS},
+		{"zh-TW, en", configs.ZhCN},
+	}
+
+	for _, tt := range tests {
+		if got := parseAcceptLanguage(tt.header); got != tt.want {
+			t.Errorf("parseAcceptLanguage(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+type bindRequest struct {
+	Name  string `json:"name" binding:"required"`
+	Count int    `json:"count" binding:"gte=1"`
+}
+
+func TestBindErrorTranslation(t *testing.T) {
+	mux, err := New(zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.Group("/api").POST("/bind", func(ctx ContextWrap) {
+		req := new(bindRequest)
+		if err := ctx.ShouldBindJSON(req); err != nil {
+			ctx.AbortWithError(ParamBindError(err))
+			return
+		}
+		ctx.Payload(req)
+	})
+
+	tests := []struct {
+		name     string
+		language string
+		want     FieldErrors
+	}{
+		{"zh", "zh-CN", FieldErrors{{"name", "name为必填字段"}, {"count", "count必须大于或等于1"}}},
+		{"en", "en-US,zh;q=0.5", FieldErrors{{"name", "name is a required field"}, {"count", "count must be 1 or greater"}}},
+		{"unsupported falls back to zh", "fr", FieldErrors{{"name", "name为必填字段"}, {"count", "count必须大于或等于1"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/bind", strings.NewReader(`{"count":0}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Accept-Language", tt.language)
+			w := serveRequest(mux, req)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("code = %d: %s", w.Code, w.Body.String())
+			}
+
+			var resp struct {
+				Data FieldErrors `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Data) != len(tt.want) {
+				t.Fatalf("field errors = %v, want %v", resp.Data, tt.want)
+			}
+			for i := range tt.want {
+				if resp.Data[i] != tt.want[i] {
+					t.Errorf("field error %d = %+v, want %+v", i, resp.Data[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTranslateBindErrorPassesOtherErrors(t *testing.T) {
+	err := json.Unmarshal([]byte("{"), new(bindRequest))
+	if got := translateBindError(err, configs.EnUS); got != err {
+		t.Errorf("translateBindError() = %v, want the original error", got)
+	}
+}
@@ -0,0 +1,486 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/kisun-bit/aio_dashboard/configs"
+	"github.com/kisun-bit/aio_dashboard/internal/code"
+	"github.com/kisun-bit/aio_dashboard/internal/proposal"
+	"go.uber.org/zap"
+)
+
+const (
+	_WebSocketName = "_websocket_"
+
+	// WSTypeSubscribe 客户端订阅主题
+	WSTypeSubscribe = "subscribe"
+	// WSTypeUnsubscribe 客户端取消订阅主题
+	WSTypeUnsubscribe = "unsubscribe"
+	// WSTypePublish 服务端推送的主题消息
+	WSTypePublish = "publish"
+	// WSTypeError 服务端拒绝客户端消息(如无权订阅主题)，Data 为 {"code": 业务码, "message": 描述}
+	WSTypeError = "error"
+
+	// wsTokenQuery 浏览器无法为 WebSocket 设置 Header，登录 Token 可通过 querystring 传递
+	wsTokenQuery = "token"
+)
+
+// WSMessage WebSocket 消息，客户端与服务端均使用该结构
+type WSMessage struct {
+	Type    string          `json:"type"`               // 消息类型，subscribe / unsubscribe / publish 或业务自定义
+	Topic   string          `json:"topic,omitempty"`    // 主题
+	Data    json.RawMessage `json:"data,omitempty"`     // 消息数据
+	TraceID string          `json:"trace_id,omitempty"` // 链路ID，服务端发送时填充为连接的链路ID
+}
+
+// WSConn WebSocket 连接，仅在连接建立期间有效
+type WSConn interface {
+	// TraceID 建立连接的请求的链路ID
+	TraceID() string
+
+	// SessionUserInfo 建立连接时的用户信息
+	SessionUserInfo() proposal.SessionUserInfo
+
+	// Logger 携带链路ID的 Logger
+	Logger() *zap.Logger
+
+	// Send 向该连接发送消息；发送缓冲区已满(客户端消费过慢)时断开连接并返回错误
+	Send(msgType, topic string, data interface{}) error
+
+	// Subscribe 订阅主题，接收 WSHub.Publish 推送的消息
+	Subscribe(topics ...string)
+
+	// Unsubscribe 取消订阅主题
+	Unsubscribe(topics ...string)
+
+	// Close 关闭连接
+	Close()
+}
+
+// WSHandler 处理客户端发送的非订阅类消息(如控制台输入)
+type WSHandler func(conn WSConn, msg WSMessage)
+
+// WSOption WebSocket 配置
+type WSOption func(*wsOption)
+
+type wsOption struct {
+	sendBuffer     int
+	idleTimeout    time.Duration
+	writeTimeout   time.Duration
+	maxMessageSize int64
+	checkOrigin    func(r *http.Request) bool
+	authorizeTopic func(ctx ContextWrap, topic string) bool
+}
+
+// WithWSSendBuffer 设置每个连接的发送缓冲区大小(消息数)，缓冲区满时断开该连接，默认 256
+func WithWSSendBuffer(size int) WSOption {
+	return func(opt *wsOption) {
+		opt.sendBuffer = size
+	}
+}
+
+// WithWSIdleTimeout 设置空闲超时，期间未收到客户端消息或 pong 时断开连接，默认 60 秒
+func WithWSIdleTimeout(timeout time.Duration) WSOption {
+	return func(opt *wsOption) {
+		opt.idleTimeout = timeout
+	}
+}
+
+// WithWSMaxMessageSize 设置客户端单条消息的最大字节数，默认 64KB
+func WithWSMaxMessageSize(size int64) WSOption {
+	return func(opt *wsOption) {
+		opt.maxMessageSize = size
+	}
+}
+
+// WithWSCheckOrigin 设置跨域校验，默认仅允许同源
+func WithWSCheckOrigin(checkOrigin func(r *http.Request) bool) WSOption {
+	return func(opt *wsOption) {
+		opt.checkOrigin = checkOrigin
+	}
+}
+
+// WithWSTopicAuthorizer 设置主题订阅鉴权，ctx 为建立连接的请求(可获取登录用户)，返回 false 时拒绝订阅并回复 error 消息；
+// 未设置时已通过入口验证的连接可订阅任意主题
+func WithWSTopicAuthorizer(authorize func(ctx ContextWrap, topic string) bool) WSOption {
+	return func(opt *wsOption) {
+		opt.authorizeTopic = authorize
+	}
+}
+
+// WSHub 管理 WebSocket 连接及主题订阅，内部模块通过 Publish 向订阅者推送消息
+type WSHub struct {
+	opt      *wsOption
+	upgrader websocket.Upgrader
+
+	mux    sync.RWMutex
+	conns  map[*wsConn]struct{}
+	topics map[string]map[*wsConn]struct{}
+}
+
+// NewWSHub 创建 WSHub
+func NewWSHub(options ...WSOption) *WSHub {
+	opt := &wsOption{
+		sendBuffer:     256,
+		idleTimeout:    time.Minute,
+		writeTimeout:   10 * time.Second,
+		maxMessageSize: 64 << 10,
+	}
+	for _, f := range options {
+		f(opt)
+	}
+
+	return &WSHub{
+		opt: opt,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  4 << 10,
+			WriteBufferSize: 4 << 10,
+			CheckOrigin:     opt.checkOrigin,
+		},
+		conns:  make(map[*wsConn]struct{}),
+		topics: make(map[string]map[*wsConn]struct{}),
+	}
+}
+
+// Publish 向订阅 topic 的所有连接推送消息，返回推送的连接数
+func (h *WSHub) Publish(topic string, data interface{}) int {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return 0
+	}
+
+	h.mux.RLock()
+	subscribers := make([]*wsConn, 0, len(h.topics[topic]))
+	for conn := range h.topics[topic] {
+		subscribers = append(subscribers, conn)
+	}
+	h.mux.RUnlock()
+
+	delivered := 0
+	for _, conn := range subscribers {
+		if conn.enqueue(WSMessage{Type: WSTypePublish, Topic: topic, Data: raw}) == nil {
+			delivered++
+		}
+	}
+	return delivered
+}
+
+// Count 当前连接数
+func (h *WSHub) Count() int {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+
+	return len(h.conns)
+}
+
+// Close 关闭所有连接
+func (h *WSHub) Close() {
+	h.mux.RLock()
+	conns := make([]*wsConn, 0, len(h.conns))
+	for conn := range h.conns {
+		conns = append(conns, conn)
+	}
+	h.mux.RUnlock()
+
+	for _, conn := range conns {
+		conn.close(websocket.CloseGoingAway, "server shutdown")
+	}
+}
+
+func (h *WSHub) register(conn *wsConn) {
+	h.mux.Lock()
+	h.conns[conn] = struct{}{}
+	h.mux.Unlock()
+}
+
+func (h *WSHub) unregister(conn *wsConn) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	delete(h.conns, conn)
+	for topic, subscribers := range h.topics {
+		delete(subscribers, conn)
+		if len(subscribers) == 0 {
+			delete(h.topics, topic)
+		}
+	}
+}
+
+func (h *WSHub) subscribe(conn *wsConn, topics ...string) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	for _, topic := range topics {
+		if h.topics[topic] == nil {
+			h.topics[topic] = make(map[*wsConn]struct{})
+		}
+		h.topics[topic][conn] = struct{}{}
+	}
+}
+
+func (h *WSHub) unsubscribe(conn *wsConn, topics ...string) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	for _, topic := range topics {
+		delete(h.topics[topic], conn)
+		if len(h.topics[topic]) == 0 {
+			delete(h.topics, topic)
+		}
+	}
+}
+
+// MountWebSocket 在 relativePath 上注册 WebSocket 入口，handlers 先于升级执行(如登录验证)；
+// 登录 Token 可通过 Header 或 querystring 中的 token 传递
+func MountWebSocket(group IRoutes, relativePath string, hub *WSHub, handle WSHandler, handlers ...HandlerFunc) {
+	chain := make([]HandlerFunc, 0, len(handlers)+2)
+	chain = append(chain, promoteWSToken)
+	chain = append(chain, handlers...)
+	chain = append(chain, hub.serve(handle))
+
+	group.GET(relativePath, chain...)
+}
+
+// promoteWSToken 将 querystring 中的登录 Token 写入 Header，使登录验证与 HTTP 接口保持一致
+func promoteWSToken(ctx ContextWrap) {
+	req := ctx.Request()
+	if req.Header.Get(configs.HeaderLoginToken) != "" {
+		return
+	}
+	if token := req.URL.Query().Get(wsTokenQuery); token != "" {
+		req.Header.Set(configs.HeaderLoginToken, token)
+	}
+}
+
+// wsSummary 连接关闭后记录至 Trace 的返回信息
+type wsSummary struct {
+	Received    int    `json:"received"`     // 收到的消息数
+	Sent        int    `json:"sent"`         // 发送的消息数
+	CloseReason string `json:"close_reason"` // 关闭原因
+}
+
+func (h *WSHub) serve(handle WSHandler) HandlerFunc {
+	return func(ctx ContextWrap) {
+		c := ctx.(*GinContext).ctx
+
+		c.Status(http.StatusSwitchingProtocols)
+		ws, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			// Upgrade 失败时已写入错误响应
+			ctx.Logger().Warn("websocket upgrade failed", zap.Error(err))
+			return
+		}
+
+		conn := &wsConn{
+			hub:     h,
+			ws:      ws,
+			traceID: traceID(ctx),
+			user:    ctx.SessionUserInfo(),
+			logger:  ctx.Logger(),
+			send:    make(chan []byte, h.opt.sendBuffer),
+			done:    make(chan struct{}),
+		}
+		c.Set(_WebSocketName, &conn.summary)
+
+		h.register(conn)
+		defer h.unregister(conn)
+
+		writerDone := make(chan struct{})
+		go func() {
+			defer close(writerDone)
+			conn.writeLoop()
+		}()
+
+		conn.readLoop(ctx, handle)
+		conn.close(websocket.CloseNormalClosure, "")
+		<-writerDone
+	}
+}
+
+type wsConn struct {
+	hub     *WSHub
+	ws      *websocket.Conn
+	traceID string
+	user    proposal.SessionUserInfo
+	logger  *zap.Logger
+
+	mux       sync.Mutex
+	send      chan []byte
+	done      chan struct{}
+	closeCode int
+	summary   wsSummary
+}
+
+func (c *wsConn) TraceID() string {
+	return c.traceID
+}
+
+func (c *wsConn) SessionUserInfo() proposal.SessionUserInfo {
+	return c.user
+}
+
+func (c *wsConn) Logger() *zap.Logger {
+	return c.logger
+}
+
+func (c *wsConn) Send(msgType, topic string, data interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return c.enqueue(WSMessage{Type: msgType, Topic: topic, Data: raw})
+}
+
+func (c *wsConn) Subscribe(topics ...string) {
+	c.hub.subscribe(c, topics...)
+}
+
+func (c *wsConn) Unsubscribe(topics ...string) {
+	c.hub.unsubscribe(c, topics...)
+}
+
+func (c *wsConn) Close() {
+	c.close(websocket.CloseNormalClosure, "")
+}
+
+// enqueue 非阻塞写入发送缓冲区，缓冲区已满时断开连接，避免慢客户端拖累推送方
+func (c *wsConn) enqueue(msg WSMessage) error {
+	msg.TraceID = c.traceID
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	select {
+	case <-c.done:
+		return websocket.ErrCloseSent
+	default:
+	}
+
+	select {
+	case c.send <- raw:
+		return nil
+	default:
+		c.closeLocked(websocket.CloseTryAgainLater, "send buffer full")
+		return websocket.ErrCloseSent
+	}
+}
+
+func (c *wsConn) close(code int, reason string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.closeLocked(code, reason)
+}
+
+func (c *wsConn) closeLocked(code int, reason string) {
+	select {
+	case <-c.done:
+		return
+	default:
+	}
+
+	c.closeCode = code
+	c.summary.CloseReason = reason
+	close(c.done)
+}
+
+// readLoop 读取客户端消息，订阅类消息由 WSHub 处理，其余交由 handle；收到任意消息或 pong 时重置空闲超时
+func (c *wsConn) readLoop(ctx ContextWrap, handle WSHandler) {
+	idle := c.hub.opt.idleTimeout
+
+	c.ws.SetReadLimit(c.hub.opt.maxMessageSize)
+	_ = c.ws.SetReadDeadline(time.Now().Add(idle))
+	c.ws.SetPongHandler(func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(idle))
+	})
+
+	for {
+		var msg WSMessage
+		if err := c.ws.ReadJSON(&msg); err != nil {
+			var netErr net.Error
+			switch {
+			case websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway):
+				c.close(websocket.CloseNormalClosure, "client closed")
+			case errors.As(err, &netErr) && netErr.Timeout():
+				c.close(websocket.CloseGoingAway, "idle timeout")
+			default:
+				c.close(websocket.CloseAbnormalClosure, err.Error())
+			}
+			return
+		}
+		_ = c.ws.SetReadDeadline(time.Now().Add(idle))
+		c.summary.Received++
+
+		switch msg.Type {
+		case WSTypeSubscribe:
+			if authorize := c.hub.opt.authorizeTopic; authorize != nil && !authorize(ctx, msg.Topic) {
+				c.logger.Warn("websocket subscribe denied", zap.String("topic", msg.Topic))
+				c.deny(ctx, msg.Topic, code.PermissionDenied)
+				continue
+			}
+			c.Subscribe(msg.Topic)
+		case WSTypeUnsubscribe:
+			c.Unsubscribe(msg.Topic)
+		default:
+			if handle != nil {
+				handle(c, msg)
+			}
+		}
+	}
+}
+
+// deny 回复 error 消息，描述按建立连接的请求语言返回
+func (c *wsConn) deny(ctx ContextWrap, topic string, businessCode int) {
+	err := Code(businessCode).withLanguage(ctx.Language())
+	_ = c.Send(WSTypeError, topic, map[string]interface{}{
+		"code":    err.BusinessCode(),
+		"message": err.Message(),
+	})
+}
+
+// writeLoop 发送缓冲区中的消息并定时 ping；连接关闭时发送 close 帧并关闭底层连接以结束 readLoop
+func (c *wsConn) writeLoop() {
+	ticker := time.NewTicker(c.hub.opt.idleTimeout / 2)
+	defer func() {
+		ticker.Stop()
+		_ = c.ws.Close()
+	}()
+
+	writeTimeout := c.hub.opt.writeTimeout
+	for {
+		select {
+		case raw := <-c.send:
+			_ = c.ws.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := c.ws.WriteMessage(websocket.TextMessage, raw); err != nil {
+				c.close(websocket.CloseAbnormalClosure, err.Error())
+				return
+			}
+			c.summary.Sent++
+		case <-ticker.C:
+			if err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout)); err != nil {
+				c.close(websocket.CloseAbnormalClosure, err.Error())
+				return
+			}
+		case <-c.done:
+			c.mux.Lock()
+			code, reason := c.closeCode, c.summary.CloseReason
+			c.mux.Unlock()
+
+			if code != websocket.CloseAbnormalClosure {
+				_ = c.ws.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(code, reason), time.Now().Add(writeTimeout))
+			}
+			return
+		}
+	}
+}
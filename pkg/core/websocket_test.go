@@ -0,0 +1,169 @@
+package core
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+func newWSTestServer(t *testing.T, hub *WSHub) string {
+	t.Helper()
+
+	mux, err := New(zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	MountWebSocket(mux.Group("/api"), "/live", hub, nil)
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(func() {
+		hub.Close()
+		srv.Close()
+	})
+	return "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/live"
+}
+
+func dialWS(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+
+	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ws.Close() })
+	return ws
+}
+
+func readWS(t *testing.T, ws *websocket.Conn) WSMessage {
+	t.Helper()
+
+	var msg WSMessage
+	_ = ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := ws.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+// waitSubscribers 等待 topic 的订阅数达到 n，订阅消息由服务端异步处理
+func waitSubscribers(t *testing.T, hub *WSHub, topic string, n int) {
+	t.Helper()
+
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		hub.mux.RLock()
+		got := len(hub.topics[topic])
+		hub.mux.RUnlock()
+		if got == n {
+			return
+		}
+	}
+	t.Fatalf("topic %s did not reach %d subscribers", topic, n)
+}
+
+func TestWSHubFanOut(t *testing.T) {
+	hub := NewWSHub()
+	url := newWSTestServer(t, hub)
+
+	jobs := []*websocket.Conn{dialWS(t, url), dialWS(t, url)}
+	other := dialWS(t, url)
+	for _, ws := range jobs {
+		if err := ws.WriteJSON(WSMessage{Type: WSTypeSubscribe, Topic: "jobs"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := other.WriteJSON(WSMessage{Type: WSTypeSubscribe, Topic: "other"}); err != nil {
+		t.Fatal(err)
+	}
+	waitSubscribers(t, hub, "jobs", 2)
+	waitSubscribers(t, hub, "other", 1)
+
+	if n := hub.Publish("jobs", map[string]int{"progress": 50}); n != 2 {
+		t.Fatalf("Publish() delivered %d, want 2", n)
+	}
+	hub.Publish("other", "ping")
+
+	for _, ws := range jobs {
+		msg := readWS(t, ws)
+		if msg.Type != WSTypePublish || msg.Topic != "jobs" || string(msg.Data) != `{"progress":50}` || msg.TraceID == "" {
+			t.Errorf("subscriber got %+v", msg)
+		}
+	}
+	if msg := readWS(t, other); msg.Topic != "other" {
+		t.Errorf("non-subscriber got %+v", msg)
+	}
+
+	if err := jobs[0].WriteJSON(WSMessage{Type: WSTypeUnsubscribe, Topic: "jobs"}); err != nil {
+		t.Fatal(err)
+	}
+	waitSubscribers(t, hub, "jobs", 1)
+	if n := hub.Publish("jobs", nil); n != 1 {
+		t.Errorf("Publish() after unsubscribe delivered %d, want 1", n)
+	}
+}
+
+func TestWSTopicAuthorizer(t *testing.T) {
+	hub := NewWSHub(WithWSTopicAuthorizer(func(ctx ContextWrap, topic string) bool {
+		return topic == "public" || ctx.Request().URL.Query().Get("role") == "admin"
+	}))
+	url := newWSTestServer(t, hub)
+
+	user := dialWS(t, url)
+	if err := user.WriteJSON(WSMessage{Type: WSTypeSubscribe, Topic: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	msg := readWS(t, user)
+	if msg.Type != WSTypeError || msg.Topic != "secret" || !strings.Contains(string(msg.Data), `"code":10114`) {
+		t.Fatalf("denied subscribe got %+v", msg)
+	}
+
+	admin := dialWS(t, url+"?role=admin")
+	if err := admin.WriteJSON(WSMessage{Type: WSTypeSubscribe, Topic: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	waitSubscribers(t, hub, "secret", 1)
+
+	if err := user.WriteJSON(WSMessage{Type: WSTypeSubscribe, Topic: "public"}); err != nil {
+		t.Fatal(err)
+	}
+	waitSubscribers(t, hub, "public", 1)
+	if n := hub.Publish("secret", nil); n != 1 {
+		t.Errorf("Publish(secret) delivered %d, want only the admin", n)
+	}
+}
+
+func TestWSBackpressureDisconnect(t *testing.T) {
+	hub := NewWSHub(WithWSSendBuffer(2))
+
+	// 不启动 writeLoop，模拟不消费消息的慢客户端
+	slow := &wsConn{hub: hub, logger: zap.NewNop(), send: make(chan []byte, 2), done: make(chan struct{})}
+	fast := &wsConn{hub: hub, logger: zap.NewNop(), send: make(chan []byte, 8), done: make(chan struct{})}
+	for _, conn := range []*wsConn{slow, fast} {
+		hub.register(conn)
+		conn.Subscribe("jobs")
+	}
+
+	for i, want := range []int{2, 2, 1, 1} {
+		if n := hub.Publish("jobs", i); n != want {
+			t.Fatalf("publish #%d delivered %d, want %d", i, n, want)
+		}
+	}
+
+	select {
+	case <-slow.done:
+	default:
+		t.Fatal("slow connection was not closed")
+	}
+	if slow.closeCode != websocket.CloseTryAgainLater || slow.summary.CloseReason != "send buffer full" {
+		t.Errorf("close = %d %q", slow.closeCode, slow.summary.CloseReason)
+	}
+	if err := slow.Send(WSTypePublish, "jobs", nil); err != websocket.ErrCloseSent {
+		t.Errorf("Send() on closed connection = %v", err)
+	}
+	if len(fast.send) != 4 {
+		t.Errorf("fast connection buffered %d messages, want 4", len(fast.send))
+	}
+}
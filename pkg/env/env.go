@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"strings"
+	"sync"
 )
 
 var (
@@ -52,15 +53,26 @@ func (e *environment) IsPro() bool {
 
 func (e *environment) i() {}
 
+var (
+	envFlag *string
+	once    sync.Once
+)
+
 func init() {
-	env := flag.String("env", "", "请输入运行环境:\n "+
+	envFlag = flag.String("env", "", "请输入运行环境:\n "+
 		fmt.Sprintf("%s:开发环境\n ", dev.Value())+
 		fmt.Sprintf("%s:测试环境\n ", fat.Value())+
 		fmt.Sprintf("%s:预上线环境\n ", uat.Value())+
 		fmt.Sprintf("%s:正式环境\n", pro.Value()))
-	flag.Parse()
+}
+
+// parse 首次读取运行环境时解析命令行参数；不在 init 中解析，以免与 go test 等注册的参数冲突
+func parse() {
+	if !flag.Parsed() {
+		flag.Parse()
+	}
 
-	switch strings.ToLower(strings.TrimSpace(*env)) {
+	switch strings.ToLower(strings.TrimSpace(*envFlag)) {
 	case dev.Value():
 		active = dev
 	case fat.Value():
@@ -77,5 +89,6 @@ func init() {
 
 // Active 当前配置的env
 func Active() Environment {
+	once.Do(parse)
 	return active
 }
@@ -0,0 +1,251 @@
+package tlsutil
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"time"
+)
+
+// CAValidity 内置 CA 的有效期
+const CAValidity = 10 * 365 * 24 * time.Hour
+
+// CA 内置证书颁发机构，用于签发备份代理的客户端证书及 mTLS 端口的服务端证书
+type CA struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+	pem  []byte
+}
+
+// LoadOrCreateCA 加载 CA 证书及私钥，均不存在时生成新的 CA
+func LoadOrCreateCA(certFile, keyFile, commonName string) (*CA, error) {
+	_, certErr := os.Stat(certFile)
+	_, keyErr := os.Stat(keyFile)
+	if os.IsNotExist(certErr) && os.IsNotExist(keyErr) {
+		if err := createCA(certFile, keyFile, commonName); err != nil {
+			return nil, err
+		}
+	}
+
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	if !cert.IsCA {
+		return nil, fmt.Errorf("%s is not a ca certificate", certFile)
+	}
+	key, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("ca private key can not sign")
+	}
+
+	return &CA{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}),
+	}, nil
+}
+
+func createCA(certFile, keyFile, commonName string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName, Organization: []string{"AIO Dashboard"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(CAValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err = WritePEM(keyFile, "PRIVATE KEY", keyDER, 0o600); err != nil {
+		return err
+	}
+	return WritePEM(certFile, "CERTIFICATE", der, 0o644)
+}
+
+// Certificate CA 证书
+func (ca *CA) Certificate() *x509.Certificate {
+	return ca.cert
+}
+
+// PEM PEM 格式的 CA 证书，下发给备份代理用于验证 mTLS 端口
+func (ca *CA) PEM() []byte {
+	return ca.pem
+}
+
+// Pool 仅包含本 CA 的证书池，用于验证客户端证书
+func (ca *CA) Pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+// minRSABits 证书签名请求中 RSA 公钥的最小长度
+const minRSABits = 2048
+
+// ParseCSR 解析 PEM 格式的证书签名请求，校验签名及公钥类型(ECDSA P-256/P-384/P-521、RSA 2048 位以上或 Ed25519)
+func ParseCSR(csrPEM []byte) (*x509.CertificateRequest, error) {
+	block, _ := pem.Decode(csrPEM)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return nil, errors.New("invalid certificate request pem")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if err = csr.CheckSignature(); err != nil {
+		return nil, err
+	}
+
+	switch key := csr.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		if curve := key.Curve; curve != elliptic.P256() && curve != elliptic.P384() && curve != elliptic.P521() {
+			return nil, fmt.Errorf("unsupported ecdsa curve %s", curve.Params().Name)
+		}
+	case *rsa.PublicKey:
+		if key.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("rsa key must be at least %d bits", minRSABits)
+		}
+	case ed25519.PublicKey:
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", key)
+	}
+	return csr, nil
+}
+
+// IssueClient 根据证书签名请求(PEM)签发客户端证书：仅使用请求中的公钥，主题由 CA 指定为 commonName
+func (ca *CA) IssueClient(csrPEM []byte, commonName string, validity time.Duration) (*x509.Certificate, error) {
+	csr, err := ParseCSR(csrPEM)
+	if err != nil {
+		return nil, err
+	}
+	return ca.IssueClientRequest(csr, commonName, validity)
+}
+
+// IssueClientRequest 根据已通过 ParseCSR 校验的证书签名请求签发客户端证书
+func (ca *CA) IssueClientRequest(csr *x509.CertificateRequest, commonName string, validity time.Duration) (*x509.Certificate, error) {
+	template, err := ca.template(commonName, validity)
+	if err != nil {
+		return nil, err
+	}
+	template.Subject.OrganizationalUnit = []string{"agent"}
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	return ca.sign(template, csr.PublicKey)
+}
+
+// IssueServer 签发服务端证书，hosts 为证书中的 IP 或域名
+func (ca *CA) IssueServer(hosts []string, validity time.Duration) (*tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	template, err := ca.template(firstOr(hosts, "localhost"), validity)
+	if err != nil {
+		return nil, err
+	}
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else if host != "" {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	cert, err := ca.sign(template, &key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Certificate{Certificate: [][]byte{cert.Raw, ca.cert.Raw}, PrivateKey: key, Leaf: cert}, nil
+}
+
+// RevokedCertificate 已吊销的证书
+type RevokedCertificate struct {
+	Serial    *big.Int
+	RevokedAt time.Time
+}
+
+// CRL 生成 DER 格式的证书吊销列表，number 须随每次吊销递增
+func (ca *CA) CRL(revoked []RevokedCertificate, number int64, validity time.Duration) ([]byte, error) {
+	entries := make([]pkix.RevokedCertificate, 0, len(revoked))
+	for _, r := range revoked {
+		entries = append(entries, pkix.RevokedCertificate{SerialNumber: r.Serial, RevocationTime: r.RevokedAt})
+	}
+
+	now := time.Now()
+	return x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:              big.NewInt(number),
+		ThisUpdate:          now,
+		NextUpdate:          now.Add(validity),
+		RevokedCertificates: entries,
+	}, ca.cert, ca.key)
+}
+
+func (ca *CA) template(commonName string, validity time.Duration) (*x509.Certificate, error) {
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	notAfter := now.Add(validity)
+	if notAfter.After(ca.cert.NotAfter) {
+		notAfter = ca.cert.NotAfter
+	}
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName, Organization: ca.cert.Subject.Organization},
+		NotBefore:    now.Add(-5 * time.Minute),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}, nil
+}
+
+func (ca *CA) sign(template *x509.Certificate, pub crypto.PublicKey) (*x509.Certificate, error) {
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, pub, ca.key)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+func randomSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
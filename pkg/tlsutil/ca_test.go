@@ -0,0 +1,217 @@
+package tlsutil
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestCA(t *testing.T) *CA {
+	t.Helper()
+
+	dir := t.TempDir()
+	ca, err := LoadOrCreateCA(filepath.Join(dir, "ca.crt"), filepath.Join(dir, "ca.key"), "Test CA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ca
+}
+
+func newCSR(t *testing.T, commonName string) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: pkix.Name{CommonName: commonName}}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})
+}
+
+func TestLoadOrCreateCA(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "ca.crt"), filepath.Join(dir, "ca.key")
+
+	created, err := LoadOrCreateCA(certFile, keyFile, "Test CA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadOrCreateCA(certFile, keyFile, "Other CA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created.Certificate().Equal(loaded.Certificate()) || loaded.Certificate().Subject.CommonName != "Test CA" {
+		t.Error("existing ca should be loaded instead of recreated")
+	}
+
+	serverCert, serverKey := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	if err = GenerateSelfSigned(serverCert, serverKey, []string{"localhost"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = LoadOrCreateCA(serverCert, serverKey, "x"); err == nil {
+		t.Error("want error for non-ca certificate")
+	}
+	if _, err = LoadOrCreateCA(certFile, filepath.Join(dir, "missing.key"), "x"); err == nil {
+		t.Error("want error when only the key is missing")
+	}
+}
+
+func TestIssueClient(t *testing.T) {
+	ca := newTestCA(t)
+
+	tests := []struct {
+		name     string
+		csr      []byte
+		validity time.Duration
+		wantErr  bool
+	}{
+		{name: "valid", csr: newCSR(t, "forged-admin"), validity: 24 * time.Hour},
+		{name: "clamped to ca", csr: newCSR(t, "a"), validity: 2 * CAValidity},
+		{name: "not pem", csr: []byte("csr"), wantErr: true},
+		{name: "wrong pem type", csr: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte{1}}), wantErr: true},
+		{name: "broken request", csr: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: []byte{1}}), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert, err := ca.IssueClient(tt.csr, "agent-1", tt.validity)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if cert.Subject.CommonName != "agent-1" {
+				t.Errorf("common name = %q, want the one chosen by ca", cert.Subject.CommonName)
+			}
+			if cert.NotAfter.After(ca.Certificate().NotAfter) {
+				t.Errorf("not after %v exceeds ca %v", cert.NotAfter, ca.Certificate().NotAfter)
+			}
+			if _, err = cert.Verify(x509.VerifyOptions{Roots: ca.Pool(), KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}); err != nil {
+				t.Errorf("verify client cert: %v", err)
+			}
+			if _, err = cert.Verify(x509.VerifyOptions{Roots: ca.Pool(), KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}}); err == nil {
+				t.Error("client cert must not be usable as server cert")
+			}
+		})
+	}
+}
+
+func csrFor(t *testing.T, key crypto.Signer) []byte {
+	t.Helper()
+
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: pkix.Name{CommonName: "agent"}}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})
+}
+
+func TestParseCSR(t *testing.T) {
+	p224, _ := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	rsa1024, _ := rsa.GenerateKey(rand.Reader, 1024)
+	rsa2048, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, ed, _ := ed25519.GenerateKey(rand.Reader)
+
+	tampered, _ := pem.Decode(newCSR(t, "agent"))
+	tampered.Bytes[len(tampered.Bytes)-1] ^= 0xff
+
+	tests := []struct {
+		name    string
+		csr     []byte
+		wantErr bool
+	}{
+		{name: "ecdsa p256", csr: newCSR(t, "agent")},
+		{name: "ecdsa p384", csr: csrFor(t, p384)},
+		{name: "rsa 2048", csr: csrFor(t, rsa2048)},
+		{name: "ed25519", csr: csrFor(t, ed)},
+		{name: "ecdsa p224", csr: csrFor(t, p224), wantErr: true},
+		{name: "rsa 1024", csr: csrFor(t, rsa1024), wantErr: true},
+		{name: "bad signature", csr: pem.EncodeToMemory(tampered), wantErr: true},
+		{name: "not pem", csr: []byte("csr"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csr, err := ParseCSR(tt.csr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && csr.PublicKey == nil {
+				t.Error("public key missing")
+			}
+		})
+	}
+}
+
+func TestIssueServer(t *testing.T) {
+	ca := newTestCA(t)
+
+	cert, err := ca.IssueServer([]string{"127.0.0.1", "dashboard.local", ""}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := cert.Leaf
+	if len(leaf.IPAddresses) != 1 || len(leaf.DNSNames) != 1 || leaf.DNSNames[0] != "dashboard.local" {
+		t.Errorf("sans = %v %v", leaf.IPAddresses, leaf.DNSNames)
+	}
+	if _, err = leaf.Verify(x509.VerifyOptions{Roots: ca.Pool(), DNSName: "dashboard.local"}); err != nil {
+		t.Errorf("verify server cert: %v", err)
+	}
+}
+
+func TestCRL(t *testing.T) {
+	ca := newTestCA(t)
+	revokedAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+
+	tests := []struct {
+		name    string
+		revoked []RevokedCertificate
+		number  int64
+	}{
+		{name: "empty", number: 0},
+		{name: "revoked", revoked: []RevokedCertificate{{Serial: big.NewInt(0xabc), RevokedAt: revokedAt}, {Serial: big.NewInt(1), RevokedAt: revokedAt}}, number: 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			der, err := ca.CRL(tt.revoked, tt.number, time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			crl, err := x509.ParseRevocationList(der)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = crl.CheckSignatureFrom(ca.Certificate()); err != nil {
+				t.Errorf("crl signature: %v", err)
+			}
+			if crl.Number.Int64() != tt.number || len(crl.RevokedCertificates) != len(tt.revoked) {
+				t.Fatalf("number = %v entries = %d", crl.Number, len(crl.RevokedCertificates))
+			}
+			for i, entry := range crl.RevokedCertificates {
+				if entry.SerialNumber.Cmp(tt.revoked[i].Serial) != 0 || !entry.RevocationTime.Equal(revokedAt) {
+					t.Errorf("entry %d = %v %v", i, entry.SerialNumber, entry.RevocationTime)
+				}
+			}
+		})
+	}
+}
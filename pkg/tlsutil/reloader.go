@@ -0,0 +1,108 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultReloadInterval 检查证书文件是否变更的间隔
+const DefaultReloadInterval = 10 * time.Second
+
+// Reloader 在证书或私钥文件变更后自动加载，用于 tls.Config.GetCertificate；
+// 新证书加载失败时继续使用原证书
+type Reloader struct {
+	certFile string
+	keyFile  string
+	logger   *zap.Logger
+
+	mux      sync.RWMutex
+	cert     *tls.Certificate
+	modTime  time.Time // 证书与私钥中较晚的修改时间
+	checkAt  time.Time
+	interval time.Duration
+}
+
+// NewReloader 加载证书，首次加载失败时返回错误
+func NewReloader(certFile, keyFile string, logger *zap.Logger) (*Reloader, error) {
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		logger:   logger,
+		interval: DefaultReloadInterval,
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate 返回当前证书，距上次检查超过 DefaultReloadInterval 时检查文件是否变更
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	now := time.Now()
+
+	r.mux.RLock()
+	cert, due := r.cert, now.After(r.checkAt)
+	r.mux.RUnlock()
+	if !due {
+		return cert, nil
+	}
+
+	r.mux.Lock()
+	r.checkAt = now.Add(r.interval)
+	r.mux.Unlock()
+
+	if modTime, err := r.latestModTime(); err == nil && modTime.After(r.currentModTime()) {
+		if err = r.load(); err != nil {
+			r.logger.Error("reload tls certificate failed, keep the current one", zap.String("cert_file", r.certFile), zap.Error(err))
+		} else {
+			r.logger.Info("tls certificate reloaded", zap.String("cert_file", r.certFile))
+		}
+	}
+
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	return r.cert, nil
+}
+
+func (r *Reloader) load() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	r.mux.Lock()
+	r.cert = &cert
+	r.modTime = modTime
+	r.checkAt = time.Now().Add(r.interval)
+	r.mux.Unlock()
+	return nil
+}
+
+func (r *Reloader) currentModTime() time.Time {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	return r.modTime
+}
+
+func (r *Reloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,142 @@
+package tlsutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultFilePerm 证书所在目录的权限
+	DefaultFilePerm = 0o755
+
+	// SelfSignedValidity 自签名证书的有效期，不超过浏览器接受的 825 天
+	SelfSignedValidity = 825 * 24 * time.Hour
+)
+
+var versions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseVersion 解析最低 TLS 版本，仅允许 1.2 或 1.3，为空时为 1.2；
+// 1.0、1.1 已不安全，配置后返回错误以拒绝启动
+func ParseVersion(version string) (uint16, error) {
+	version = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(version), "TLS"))
+	if version == "" {
+		return tls.VersionTLS12, nil
+	}
+	if v, ok := versions[version]; ok {
+		return v, nil
+	}
+	if version == "1.0" || version == "1.1" {
+		return 0, fmt.Errorf("insecure tls version %q, minimum is 1.2", version)
+	}
+	return 0, fmt.Errorf("unknown tls version %q", version)
+}
+
+// ParseCipherSuites 按名称(如 TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256)解析 TLS 1.2 及以下的加密套件，
+// 不允许不安全的套件；为空时返回 nil，即使用 Go 的默认套件。TLS 1.3 的套件不可配置
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	supported := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		supported[suite.Name] = suite.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := supported[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unsupported or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// GenerateSelfSigned 生成 ECDSA P-256 自签名证书，hosts 为证书中的 IP 或域名
+func GenerateSelfSigned(certFile, keyFile string, hosts []string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	serial, err := randomSerial()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: firstOr(hosts, "localhost"), Organization: []string{"AIO Dashboard"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(SelfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	seen := make(map[string]bool)
+	for _, host := range hosts {
+		if seen[host] {
+			continue
+		}
+		seen[host] = true
+
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else if host != "" {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err = WritePEM(keyFile, "PRIVATE KEY", keyDER, 0o600); err != nil {
+		return err
+	}
+	return WritePEM(certFile, "CERTIFICATE", der, 0o644)
+}
+
+// WritePEM 以 PEM 格式写入文件，先写入临时文件再重命名，避免热加载读取到不完整的内容
+func WritePEM(path, blockType string, der []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), DefaultFilePerm); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func firstOr(values []string, fallback string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,41 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    uint16
+		wantErr bool
+	}{
+		{"", tls.VersionTLS12, false},
+		{"1.2", tls.VersionTLS12, false},
+		{"TLS1.3", tls.VersionTLS13, false},
+		{" TLS 1.3 ", tls.VersionTLS13, false},
+		{"1.0", 0, true},
+		{"TLS1.1", 0, true},
+		{"2.0", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseVersion(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseVersion(%q) = %v, %v; want %v, err %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseCipherSuites(t *testing.T) {
+	if ids, err := ParseCipherSuites(nil); ids != nil || err != nil {
+		t.Errorf("empty = %v, %v", ids, err)
+	}
+	if ids, err := ParseCipherSuites([]string{" TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 "}); err != nil || len(ids) != 1 {
+		t.Errorf("secure suite = %v, %v", ids, err)
+	}
+	if _, err := ParseCipherSuites([]string{"TLS_RSA_WITH_RC4_128_SHA"}); err == nil {
+		t.Error("want error for insecure suite")
+	}
+}
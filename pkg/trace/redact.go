@@ -0,0 +1,337 @@
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Mask 脱敏后的占位内容
+const Mask = "******"
+
+// RedactOption 脱敏规则
+type RedactOption func(*redactOption)
+
+type redactOption struct {
+	headers       []string
+	fields        []string
+	patterns      []string
+	redisPrefixes []string
+}
+
+// RedactHeaders 按名称(不区分大小写)脱敏 Header
+func RedactHeaders(names ...string) RedactOption {
+	return func(opt *redactOption) {
+		opt.headers = append(opt.headers, names...)
+	}
+}
+
+// RedactJSONFields 按路径脱敏 JSON 字段：不含 "." 的字段名在任意层级匹配(如 password)，
+// 含 "." 的路径从根开始匹配(如 data.credential.secret)，"*" 匹配任意字段，数组不占用路径层级；
+// 不含 "." 的字段名同时用于脱敏 URL 中的同名参数
+func RedactJSONFields(paths ...string) RedactOption {
+	return func(opt *redactOption) {
+		opt.fields = append(opt.fields, paths...)
+	}
+}
+
+// RedactPatterns 按正则脱敏请求地址与 Body 文本：含分组时仅脱敏分组内容，否则脱敏整个匹配
+func RedactPatterns(patterns ...string) RedactOption {
+	return func(opt *redactOption) {
+		opt.patterns = append(opt.patterns, patterns...)
+	}
+}
+
+// RedactRedisPrefixes 按 Key 前缀脱敏 Redis 的 Value
+func RedactRedisPrefixes(prefixes ...string) RedactOption {
+	return func(opt *redactOption) {
+		opt.redisPrefixes = append(opt.redisPrefixes, prefixes...)
+	}
+}
+
+// Redactor 记录 Trace 前对请求与返回信息进行脱敏
+type Redactor struct {
+	headers       map[string]struct{}
+	names         map[string]struct{} // 任意层级匹配的字段名
+	paths         [][]string          // 从根开始匹配的字段路径
+	patterns      []*regexp.Regexp
+	redisPrefixes []string
+}
+
+// NewRedactor 创建脱敏器，正则无效时返回错误
+func NewRedactor(options ...RedactOption) (*Redactor, error) {
+	opt := new(redactOption)
+	for _, f := range options {
+		f(opt)
+	}
+
+	r := &Redactor{
+		headers: make(map[string]struct{}),
+		names:   make(map[string]struct{}),
+	}
+	for _, name := range opt.headers {
+		if name = strings.TrimSpace(name); name != "" {
+			r.headers[http.CanonicalHeaderKey(name)] = struct{}{}
+		}
+	}
+	for _, path := range opt.fields {
+		path = strings.TrimSpace(path)
+		switch {
+		case path == "":
+		case !strings.Contains(path, "."):
+			r.names[strings.ToLower(path)] = struct{}{}
+		default:
+			r.paths = append(r.paths, strings.Split(strings.ToLower(path), "."))
+		}
+	}
+	for _, pattern := range opt.patterns {
+		if pattern = strings.TrimSpace(pattern); pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	for _, prefix := range opt.redisPrefixes {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			r.redisPrefixes = append(r.redisPrefixes, prefix)
+		}
+	}
+
+	return r, nil
+}
+
+// Apply 对 Trace 中的第三方接口调用及 Redis 信息进行脱敏；
+// 本次请求的 Request、Response 须在写入 Trace 时通过 URL、Header、Body 脱敏，不经过 Apply
+func (r *Redactor) Apply(t *Trace) {
+	if r == nil || t == nil {
+		return
+	}
+
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	for _, dialog := range t.ThirdPartyRequests {
+		r.request(dialog.Request)
+		for _, resp := range dialog.Responses {
+			r.response(resp)
+		}
+	}
+	for _, redis := range t.Redis {
+		redis.Value = r.RedisValue(redis.Key, redis.Value)
+	}
+}
+
+func (r *Redactor) request(req *Request) {
+	if req == nil {
+		return
+	}
+
+	req.DecodedURL = r.URL(req.DecodedURL)
+	req.Header = r.Header(req.Header)
+	req.Body = r.Body(req.Body)
+}
+
+func (r *Redactor) response(resp *Response) {
+	if resp == nil {
+		return
+	}
+
+	resp.Header = r.Header(resp.Header)
+	resp.Body = r.Body(resp.Body)
+}
+
+// Header 返回脱敏后的 Header 副本，不修改原 Header
+func (r *Redactor) Header(header interface{}) interface{} {
+	h, ok := header.(http.Header)
+	if !ok || r == nil || len(r.headers) == 0 {
+		return header
+	}
+
+	masked := make(http.Header, len(h))
+	for key, values := range h {
+		if _, ok := r.headers[http.CanonicalHeaderKey(key)]; ok {
+			masked[key] = []string{Mask}
+			continue
+		}
+		masked[key] = values
+	}
+	return masked
+}
+
+// URL 脱敏 URL 中与字段名同名的参数，并应用正则
+func (r *Redactor) URL(rawURL string) string {
+	if r == nil {
+		return rawURL
+	}
+	if i := strings.IndexByte(rawURL, '?'); i >= 0 && len(r.names) > 0 {
+		params := strings.Split(rawURL[i+1:], "&")
+		for j, param := range params {
+			key := strings.SplitN(param, "=", 2)[0]
+			if unescaped, err := url.QueryUnescape(key); err == nil {
+				key = unescaped
+			}
+			if _, ok := r.names[strings.ToLower(key)]; ok {
+				params[j] = strings.SplitN(param, "=", 2)[0] + "=" + Mask
+			}
+		}
+		rawURL = rawURL[:i+1] + strings.Join(params, "&")
+	}
+	return r.text(rawURL)
+}
+
+// Body 脱敏 Body：字符串形式的 JSON 按字段脱敏后仍以字符串返回，其余字符串仅应用正则；
+// 结构体等其他类型先编码为 JSON 再脱敏，以 json.RawMessage 返回
+func (r *Redactor) Body(body interface{}) interface{} {
+	if r == nil {
+		return body
+	}
+
+	switch v := body.(type) {
+	case nil:
+		return nil
+	case string:
+		if masked, ok := r.json([]byte(v)); ok {
+			return r.text(string(masked))
+		}
+		return r.text(v)
+	case []byte:
+		return r.Body(string(v))
+	default:
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return body
+		}
+		if masked, ok := r.json(raw); ok {
+			raw = masked
+		}
+		return json.RawMessage(r.text(string(raw)))
+	}
+}
+
+// RedisValue 脱敏 Key 前缀匹配的 Redis Value
+func (r *Redactor) RedisValue(key, value string) string {
+	if r == nil || value == "" {
+		return value
+	}
+	for _, prefix := range r.redisPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return Mask
+		}
+	}
+	return r.text(value)
+}
+
+// Text 对任意文本(如告警的错误信息及堆栈)应用正则
+func (r *Redactor) Text(s string) string {
+	if r == nil {
+		return s
+	}
+	return r.text(s)
+}
+
+func (r *Redactor) json(raw []byte) ([]byte, bool) {
+	if len(r.names) == 0 && len(r.paths) == 0 {
+		return nil, false
+	}
+
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return nil, false
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(trimmed))
+	decoder.UseNumber()
+
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return nil, false
+	}
+
+	masked, err := json.Marshal(r.walk(v, nil))
+	if err != nil {
+		return nil, false
+	}
+	return masked, true
+}
+
+func (r *Redactor) walk(v interface{}, path []string) interface{} {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		for key, child := range node {
+			childPath := append(path[:len(path):len(path)], strings.ToLower(key))
+			if r.match(childPath) {
+				node[key] = Mask
+				continue
+			}
+			node[key] = r.walk(child, childPath)
+		}
+		return node
+	case []interface{}:
+		for i, child := range node {
+			node[i] = r.walk(child, path)
+		}
+		return node
+	default:
+		return v
+	}
+}
+
+func (r *Redactor) match(path []string) bool {
+	if _, ok := r.names[path[len(path)-1]]; ok {
+		return true
+	}
+
+	for _, rule := range r.paths {
+		if len(rule) != len(path) {
+			continue
+		}
+
+		matched := true
+		for i := range rule {
+			if rule[i] != "*" && rule[i] != path[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Redactor) text(s string) string {
+	for _, re := range r.patterns {
+		if re.NumSubexp() == 0 {
+			s = re.ReplaceAllLiteralString(s, Mask)
+			continue
+		}
+		s = replaceSubmatches(re, s)
+	}
+	return s
+}
+
+// replaceSubmatches 仅将正则各分组匹配的内容替换为 Mask
+func replaceSubmatches(re *regexp.Regexp, s string) string {
+	var buf strings.Builder
+	last := 0
+	for _, loc := range re.FindAllStringSubmatchIndex(s, -1) {
+		for i := 2; i+1 < len(loc); i += 2 {
+			start, end := loc[i], loc[i+1]
+			if start < last || start < 0 {
+				continue
+			}
+			buf.WriteString(s[last:start])
+			buf.WriteString(Mask)
+			last = end
+		}
+	}
+	buf.WriteString(s[last:])
+	return buf.String()
+}
@@ -0,0 +1,162 @@
+package trace
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func newTestRedactor(t *testing.T) *Redactor {
+	t.Helper()
+
+	r, err := NewRedactor(
+		RedactHeaders("Token", "authorization"),
+		RedactJSONFields("password", "data.credential.*", "items.secret"),
+		RedactPatterns(`(?i)key=([^&\s]*)`, `\b\d{16}\b`),
+		RedactRedisPrefixes("dashboard:login-user:"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestNewRedactorInvalidPattern(t *testing.T) {
+	if _, err := NewRedactor(RedactPatterns("(")); err == nil {
+		t.Fatal("want error for invalid pattern")
+	}
+}
+
+func TestRedactorHeader(t *testing.T) {
+	r := newTestRedactor(t)
+	header := http.Header{"Token": {"abc"}, "Authorization": {"Bearer x"}, "Accept": {"*/*"}}
+
+	masked := r.Header(header).(http.Header)
+	if masked.Get("Token") != Mask || masked.Get("Authorization") != Mask || masked.Get("Accept") != "*/*" {
+		t.Errorf("masked header = %v", masked)
+	}
+	if header.Get("Token") != "abc" {
+		t.Error("original header modified")
+	}
+	if got := r.Header("not a header"); got != "not a header" {
+		t.Errorf("non header value = %v", got)
+	}
+}
+
+func TestRedactorURL(t *testing.T) {
+	r := newTestRedactor(t)
+
+	tests := []struct {
+		in, want string
+	}{
+		{"/api/login", "/api/login"},
+		{"/api/login?user=a&password=123", "/api/login?user=a&password=" + Mask},
+		{"/api/login?PassWord=123", "/api/login?PassWord=" + Mask},
+		{"/api/login?pass%77ord=123", "/api/login?pass%77ord=" + Mask},
+		{"/api/files?key=abc&x=1", "/api/files?key=" + Mask + "&x=1"},
+	}
+
+	for _, tt := range tests {
+		if got := r.URL(tt.in); got != tt.want {
+			t.Errorf("URL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRedactorBody(t *testing.T) {
+	r := newTestRedactor(t)
+
+	tests := []struct {
+		name string
+		in   interface{}
+		want string
+	}{
+		{"nil", nil, "null"},
+		{"field at any level", `{"user":{"Password":"p"},"name":"a"}`, `{"name":"a","user":{"Password":"******"}}`},
+		{"path wildcard", `{"data":{"credential":{"id":1,"secret":"s"},"other":2}}`, `{"data":{"credential":{"id":"******","secret":"******"},"other":2}}`},
+		{"path not from root", `{"x":{"data":{"credential":{"id":1}}}}`, `{"x":{"data":{"credential":{"id":1}}}}`},
+		{"arrays keep path", `{"items":[{"secret":"a"},{"secret":"b"}]}`, `{"items":[{"secret":"******"},{"secret":"******"}]}`},
+		{"large numbers kept", `{"id":12345678901234567890}`, `{"id":12345678901234567890}`},
+		{"plain text pattern", "card 1234567812345678 paid", `"card ****** paid"`},
+		{"bytes", []byte(`{"password":"p"}`), `{"password":"******"}`},
+		{"struct", struct {
+			Password string `json:"password"`
+			Name     string `json:"name"`
+		}{"p", "a"}, `{"name":"a","password":"******"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := r.Body(tt.in)
+			var raw []byte
+			switch v := got.(type) {
+			case string:
+				if json.Valid([]byte(v)) {
+					raw = []byte(v)
+				} else {
+					raw, _ = json.Marshal(v)
+				}
+			default:
+				raw, _ = json.Marshal(v)
+			}
+			if string(raw) != tt.want {
+				t.Errorf("Body() = %s, want %s", raw, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactorRedisValueAndText(t *testing.T) {
+	r := newTestRedactor(t)
+
+	if got := r.RedisValue("dashboard:login-user:abc", `{"id":1}`); got != Mask {
+		t.Errorf("prefixed redis value = %q", got)
+	}
+	if got := r.RedisValue("dashboard:other", "key=abc"); got != "key="+Mask {
+		t.Errorf("redis value = %q", got)
+	}
+	if got := r.Text("dial http://x?key=abc failed"); got != "dial http://x?key="+Mask+" failed" {
+		t.Errorf("Text() = %q", got)
+	}
+}
+
+func TestRedactorNil(t *testing.T) {
+	var r *Redactor
+
+	if got := r.URL("/a?password=1"); got != "/a?password=1" {
+		t.Errorf("nil URL() = %q", got)
+	}
+	if got := r.Body("x"); got != "x" {
+		t.Errorf("nil Body() = %v", got)
+	}
+	if got := r.Text("x"); got != "x" {
+		t.Errorf("nil Text() = %q", got)
+	}
+	r.Apply(New(""))
+}
+
+func TestRedactorApply(t *testing.T) {
+	r := newTestRedactor(t)
+
+	tr := New("")
+	tr.AppendDialog(&Dialog{
+		Request:   &Request{DecodedURL: "/remote?password=1", Header: http.Header{"Token": {"t"}}, Body: `{"password":"p"}`},
+		Responses: []*Response{{Body: `{"password":"p"}`}},
+	})
+	tr.AppendRedis(&Redis{Key: "dashboard:login-user:1", Value: "session"})
+	r.Apply(tr)
+
+	dialog := tr.ThirdPartyRequests[0]
+	if dialog.Request.DecodedURL != "/remote?password="+Mask {
+		t.Errorf("dialog url = %q", dialog.Request.DecodedURL)
+	}
+	if dialog.Request.Header.(http.Header).Get("Token") != Mask {
+		t.Errorf("dialog header = %v", dialog.Request.Header)
+	}
+	if dialog.Responses[0].Body != `{"password":"******"}` {
+		t.Errorf("dialog response body = %v", dialog.Responses[0].Body)
+	}
+	if tr.Redis[0].Value != Mask {
+		t.Errorf("redis value = %q", tr.Redis[0].Value)
+	}
+}
@@ -0,0 +1,44 @@
+package web
+
+import (
+	"embed"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/kisun-bit/aio_dashboard/pkg/env"
+)
+
+//go:embed dist templates
+var assets embed.FS
+
+// Dist 前端 SPA 构建产物；dev 环境直接读取磁盘上的 web/dist 以便实时修改
+func Dist() fs.FS {
+	return sub("dist")
+}
+
+// Templates 服务端渲染模板，供 ContextWrap.HTML 使用；dev 环境直接读取磁盘上的 web/templates
+func Templates() fs.FS {
+	return sub("templates")
+}
+
+func sub(dir string) fs.FS {
+	if env.Active().IsDev() {
+		if wd, err := os.Getwd(); err == nil {
+			if path := filepath.Join(wd, "web", dir); isDir(path) {
+				return os.DirFS(path)
+			}
+		}
+	}
+
+	fsys, err := fs.Sub(assets, dir)
+	if err != nil {
+		panic(err)
+	}
+	return fsys
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}